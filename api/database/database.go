@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// sql drivers
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// supported dialects
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// DefaultTimeout bounds a call of the stores to the db when they are not given a timeout
+const DefaultTimeout = 5 * time.Second
+
+// WithTimeout returns the context of a call to the db, done after the timeout or DefaultTimeout when it is zero.
+// The context of the request is passed in, so a client going away cancels the call as well
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// DB wraps a sql.DB along with the dialect it talks, so the repositories can write their
+// queries once with `?` placeholders and have them rebound for the db in use
+type DB struct {
+	*sql.DB
+	Dialect string
+}
+
+// Open opens a connection pool to the db of the passed dialect and runs the migrations on it
+func Open(dialect, dsn string) (*DB, error) {
+	var driver string
+	switch dialect {
+	case SQLite:
+		driver = "sqlite"
+	case Postgres:
+		driver = "pgx"
+	default:
+		return nil, fmt.Errorf("unknown sql dialect %q", dialect)
+	}
+
+	// sqlite keeps the timestamps as text, the driver writes them all in one format
+	// ("2006-01-02 15:04:05.999999999-07:00") instead of the one of time.Time.String
+	if dialect == SQLite {
+		dsn = withQueryParam(dsn, "_time_format", "sqlite")
+	}
+
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer, and every connection to :memory: is a different db
+	if dialect == SQLite {
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	db := &DB{DB: sqlDB, Dialect: dialect}
+	if err := db.Migrate(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// withQueryParam adds the param to the query of the dsn, unless it is set already
+func withQueryParam(dsn, key, value string) string {
+	if strings.Contains(dsn, key+"=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + key + "=" + value
+	}
+	return dsn + "?" + key + "=" + value
+}
+
+// Rebind replaces the `?` placeholders of the query with the ones of the dialect in use
+func (db *DB) Rebind(query string) string {
+	if db.Dialect != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	db := &DB{Dialect: Postgres}
+	assert.Equal(t, "SELECT 1 FROM votes WHERE a = $1 AND b = $2", db.Rebind("SELECT 1 FROM votes WHERE a = ? AND b = ?"))
+
+	db = &DB{Dialect: SQLite}
+	assert.Equal(t, "SELECT 1 FROM votes WHERE a = ?", db.Rebind("SELECT 1 FROM votes WHERE a = ?"))
+}
+
+func TestWithTimeout(t *testing.T) {
+	// Test case: no timeout falls back to the default
+	ctx, cancel := WithTimeout(context.Background(), 0)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(DefaultTimeout), deadline, time.Second)
+
+	// Test case: the call is cancelled along with the request
+	request, cancelRequest := context.WithCancel(context.Background())
+	ctx, cancel = WithTimeout(request, time.Minute)
+	defer cancel()
+	cancelRequest()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestMigrateTimestamps(t *testing.T) {
+	db, err := Open(SQLite, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// votes stamped by migration 3 and by the driver before it had a format
+	_, err = db.Exec(`INSERT INTO votes (product_id, session_id, rate, created_at, updated_at) VALUES
+		('p1', 's1', 4, '2024-05-01 12:00:00', '2024-05-01 12:00:00'),
+		('p1', 's2', 5, '2024-05-01 12:00:00.5 +0000 UTC', '2024-05-01 12:00:00.5 +0000 UTC')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM schema_migrations_sqlite`)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	// Test case: the timestamps are written like the ones of the driver
+	var count int
+	for _, cast := range []time.Time{time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 12, 0, 0, 5e8, time.UTC)} {
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM votes WHERE created_at = ? AND updated_at = ?`, cast, cast).Scan(&count))
+		assert.Equal(t, 1, count)
+	}
+
+	// Test case: the timestamps sort by time
+	var sessionID string
+	require.NoError(t, db.QueryRow(`SELECT session_id FROM votes WHERE created_at > ?`, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)).Scan(&sessionID))
+	assert.Equal(t, "s2", sessionID)
+}
+
+func TestWithQueryParam(t *testing.T) {
+	assert.Equal(t, "votes.db?_time_format=sqlite", withQueryParam("votes.db", "_time_format", "sqlite"))
+	assert.Equal(t, "file:votes.db?_pragma=foreign_keys(1)&_time_format=sqlite", withQueryParam("file:votes.db?_pragma=foreign_keys(1)", "_time_format", "sqlite"))
+
+	// Test case: the param set in the dsn is kept
+	assert.Equal(t, "votes.db?_time_format=sqlite", withQueryParam("votes.db?_time_format=sqlite", "_time_format", "sqlite"))
+}
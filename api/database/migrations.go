@@ -0,0 +1,119 @@
+package database
+
+// migrations holds the schema changes in the order they have to be applied.
+// The statements are kept portable between sqlite and postgres.
+// Never edit an existing migration, append a new one instead
+var migrations = []string{
+	// 1: products and votes, composite key so a session has a single vote per product
+	`CREATE TABLE products (
+		product_id   TEXT PRIMARY KEY,
+		product_name TEXT NOT NULL
+	);
+	CREATE TABLE votes (
+		product_id TEXT    NOT NULL,
+		session_id TEXT    NOT NULL,
+		rate       INTEGER NOT NULL,
+		PRIMARY KEY (product_id, session_id)
+	);
+	CREATE INDEX votes_session_id_idx ON votes (session_id);`,
+
+	// 2: details of the products, price in minor units. active is null for the older products, which are active
+	`ALTER TABLE products ADD COLUMN category TEXT NOT NULL DEFAULT '';
+	ALTER TABLE products ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE products ADD COLUMN image_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE products ADD COLUMN price BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE products ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+	ALTER TABLE products ADD COLUMN active BOOLEAN;`,
+
+	// 3: time the votes were first cast, the existing votes get the time of the migration
+	`ALTER TABLE votes ADD COLUMN created_at TIMESTAMP;
+	UPDATE votes SET created_at = CURRENT_TIMESTAMP;
+	CREATE INDEX votes_rate_idx ON votes (rate, product_id, session_id);
+	CREATE INDEX votes_created_at_idx ON votes (created_at, product_id, session_id);`,
+
+	// 4: time the votes were last posted and the append-only history of their changes
+	`ALTER TABLE votes ADD COLUMN updated_at TIMESTAMP;
+	UPDATE votes SET updated_at = created_at;
+	CREATE TABLE vote_history (
+		product_id TEXT      NOT NULL,
+		session_id TEXT      NOT NULL,
+		old_rate   INTEGER,
+		new_rate   INTEGER   NOT NULL,
+		changed_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX vote_history_product_id_idx ON vote_history (product_id, changed_at);`,
+
+	// 5: votes can be deleted, new_rate is 0 for the deletions in the history
+	`ALTER TABLE vote_history ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+	// 6: votes flagged by the abuse guard, left out of the avgs
+	`ALTER TABLE votes ADD COLUMN suspicious BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+	// 7: api keys, only the hash of their token is saved
+	`CREATE TABLE api_keys (
+		id         TEXT      PRIMARY KEY,
+		name       TEXT      NOT NULL,
+		role       TEXT      NOT NULL,
+		hash       TEXT      NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);`,
+}
+
+// sqliteMigrations holds the changes of the sqlite dbs only, applied after the portable ones.
+// sqlite keeps the timestamps as text, compared as such by the cursors and the trends,
+// so they are all written in the format of the driver, see Open
+var sqliteMigrations = []string{
+	// 1: the timestamps written before the driver had a format, by CURRENT_TIMESTAMP in migration 3
+	// (in UTC, to the second) or by the driver with the format of time.Time.String (the times of the stores are in UTC)
+	`UPDATE votes SET created_at = replace(created_at, ' +0000 UTC', '+00:00') WHERE created_at LIKE '% +0000 UTC';
+	UPDATE votes SET created_at = created_at || '+00:00' WHERE length(created_at) = 19;
+	UPDATE votes SET updated_at = replace(updated_at, ' +0000 UTC', '+00:00') WHERE updated_at LIKE '% +0000 UTC';
+	UPDATE votes SET updated_at = updated_at || '+00:00' WHERE length(updated_at) = 19;
+	UPDATE vote_history SET changed_at = replace(changed_at, ' +0000 UTC', '+00:00') WHERE changed_at LIKE '% +0000 UTC';
+	UPDATE api_keys SET created_at = replace(created_at, ' +0000 UTC', '+00:00') WHERE created_at LIKE '% +0000 UTC';
+	UPDATE api_keys SET revoked_at = replace(revoked_at, ' +0000 UTC', '+00:00') WHERE revoked_at LIKE '% +0000 UTC';`,
+}
+
+// Migrate applies the migrations that were not applied to the db yet.
+// Applied versions are tracked in the schema_migrations table, and the ones of sqlite in schema_migrations_sqlite
+func (db *DB) Migrate() error {
+	if err := db.migrate("schema_migrations", migrations); err != nil {
+		return err
+	}
+	if db.Dialect == SQLite {
+		return db.migrate("schema_migrations_sqlite", sqliteMigrations)
+	}
+	return nil
+}
+
+// migrate applies the migrations whose versions are not in the table yet
+func (db *DB) migrate(table string, migrations []string) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + table + ` (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM ` + table).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(db.Rebind(`INSERT INTO `+table+` (version) VALUES (?)`), i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
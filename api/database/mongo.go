@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// MongoURIFromEnv builds the atlas connection string from the MONGO_* env variables
+func MongoURIFromEnv() string {
+	mongoPass := os.Getenv("MONGO_PASS")
+	mongoUser := os.Getenv("MONGO_USER")
+	mongoHost := os.Getenv("MONGO_HOST")
+	mongoParams := os.Getenv("MONGO_PARAMS")
+
+	return fmt.Sprintf("mongodb+srv://%s:%s@%s/%s",
+		mongoUser, mongoPass, mongoHost, mongoParams)
+}
+
+// DefaultMongoDB is the database holding the collections when no other is configured
+const DefaultMongoDB = "trial"
+
+// MongoDBFromEnv returns the name of the database from the MONGO_DB env variable, DefaultMongoDB when unset
+func MongoDBFromEnv() string {
+	if name := os.Getenv("MONGO_DB"); name != "" {
+		return name
+	}
+	return DefaultMongoDB
+}
+
+// MongoDatabase returns the database with the passed name, DefaultMongoDB when empty
+func MongoDatabase(client *mongo.Client, name string) *mongo.Database {
+	if name == "" {
+		name = DefaultMongoDB
+	}
+	return client.Database(name)
+}
+
+// ConnectMongo creates a client connected to the passed mongo and pings it to make sure it is reachable.
+// Every command of the client is traced as a child of the span of its context
+func ConnectMongo(ctx context.Context, connectionString string) (*mongo.Client, error) {
+	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+	opts := options.Client().ApplyURI(connectionString).SetServerAPIOptions(serverAPI).SetMonitor(otelmongo.NewMonitor())
+	// Create a new client and connect to the server
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send a ping to confirm a successful connection
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Err(); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"api_assignment/api/models/product"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create a product
+// @Description Adds a new product to the catalog.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param product body product.Product true "Product to create"
+// @Success 201 {object} product.Product
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/products [post]
+func (app *Application) CreateProductHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "CreateProductHandler")
+		defer span.End()
+
+		newProduct := &product.Product{}
+		if err := c.ShouldBindJSON(newProduct); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "request is invalid. Please check your request"})
+			app.log(c).Debug("invalid request body", "error", err)
+			return
+		}
+
+		if err := newProduct.Validate(); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := app.Products.Create(c.Request.Context(), newProduct); err != nil {
+			app.productWriteError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, newProduct)
+	}
+}
+
+// @Summary Replace a product
+// @Description Replaces all the fields of an existing product.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param product body product.Product true "New product data"
+// @Success 200 {object} product.Product
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/products/{id} [put]
+func (app *Application) UpdateProductHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "UpdateProductHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		updated := &product.Product{}
+		if err := c.ShouldBindJSON(updated); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "request is invalid. Please check your request"})
+			app.log(c).Debug("invalid request body", "error", err)
+			return
+		}
+
+		// the id can be left out of the body, but can't be changed
+		if updated.ID != "" && updated.ID != productID {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "id of the body does not match the one of the path"})
+			return
+		}
+		updated.ID = productID
+
+		if err := updated.Validate(); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := app.Products.Update(c.Request.Context(), updated); err != nil {
+			app.productWriteError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, updated)
+	}
+}
+
+// productPatch holds the fields that can be changed through PATCH, nil fields are left untouched
+type productPatch struct {
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"image_url"`
+	Price       *int64  `json:"price"`
+	Currency    *string `json:"currency"`
+	Active      *bool   `json:"active"`
+}
+
+// @Summary Update a product partially
+// @Description Updates only the fields of the product present in the body.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param product body productPatch true "Fields to update"
+// @Success 200 {object} product.Product
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/products/{id} [patch]
+func (app *Application) PatchProductHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "PatchProductHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		patch := &productPatch{}
+		if err := c.ShouldBindJSON(patch); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "request is invalid. Please check your request"})
+			app.log(c).Debug("invalid request body", "error", err)
+			return
+		}
+
+		// the patch is merged into the product as it is when the write happens
+		var invalid error
+		updated, err := app.Products.Patch(c.Request.Context(), productID, func(pr *product.Product) error {
+			patch.apply(pr)
+			invalid = pr.Validate()
+			return invalid
+		})
+		if invalid != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": invalid.Error()})
+			return
+		}
+		if err != nil {
+			app.productWriteError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, updated)
+	}
+}
+
+// apply sets the fields of the patch on the product
+func (patch *productPatch) apply(pr *product.Product) {
+	if patch.Name != nil {
+		pr.Name = *patch.Name
+	}
+	if patch.Category != nil {
+		pr.Category = *patch.Category
+	}
+	if patch.Description != nil {
+		pr.Description = *patch.Description
+	}
+	if patch.ImageURL != nil {
+		pr.ImageURL = *patch.ImageURL
+	}
+	if patch.Price != nil {
+		pr.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		pr.Currency = *patch.Currency
+	}
+	if patch.Active != nil {
+		pr.Active = patch.Active
+	}
+}
+
+// @Summary Delete a product
+// @Description Removes a product from the catalog, its votes are kept.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/products/{id} [delete]
+func (app *Application) DeleteProductHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "DeleteProductHandler")
+		defer span.End()
+
+		if err := app.Products.Delete(c.Request.Context(), c.Param("id")); err != nil {
+			app.productWriteError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "The product has been deleted"})
+	}
+}
+
+// productWriteError responds to a failed write of the catalog with the matching status
+func (app *Application) productWriteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, product.ErrNotFound):
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
+	case errors.Is(err, product.ErrAlreadyExists):
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "A product with this id already exists"})
+	default:
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+		app.log(c).Error("saving the product failed", "error", err)
+	}
+}
+
+// @Summary Reload the products
+// @Description Replaces the in-memory catalog with the products saved in the db, to pick up products changed directly in the db.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/products/reload [post]
+func (app *Application) ReloadProductsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "ReloadProductsHandler")
+		defer span.End()
+
+		if err := app.Products.Reload(c.Request.Context()); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("reloading the products failed", "error", err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "The products have been reloaded", "products": app.Products.Len()})
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"api_assignment/api/middleware"
+	"api_assignment/api/models/apikey"
+	"api_assignment/api/models/product"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-token"
+
+// setupAdminRouter sets up test router with the admin endpoints
+func setupAdminRouter(app *Application) *gin.Engine {
+	router := setupRouter(app)
+
+	admin := router.Group("/admin", middleware.Authenticate(apikey.NewMemoryStore(), testAdminToken), middleware.RequireRole(apikey.RoleAdmin))
+	admin.POST("/products", app.CreateProductHandler())
+	admin.POST("/products/reload", app.ReloadProductsHandler())
+	admin.PUT("/products/:id", app.UpdateProductHandler())
+	admin.PATCH("/products/:id", app.PatchProductHandler())
+	admin.DELETE("/products/:id", app.DeleteProductHandler())
+	return router
+}
+
+// adminRequest sends an authenticated request to the router
+func adminRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := setupAdminRouter(&Application{Products: newTestCatalog()})
+
+	// Test case: No token
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/admin/products/p1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Test case: Wrong token
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/products/p1", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestCreateProductHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{Products: newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"})}
+	router := setupAdminRouter(app)
+
+	// Test case: Product created
+	w := adminRequest(router, http.MethodPost, "/admin/products", `{"id": "p2", "name": "Product 2"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	pr, ok := app.Products.Get("p2")
+	assert.True(t, ok)
+	assert.Equal(t, "Product 2", pr.Name)
+
+	// Test case: Duplicated id
+	w = adminRequest(router, http.MethodPost, "/admin/products", `{"id": "p1", "name": "Other"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Test case: Empty name
+	w = adminRequest(router, http.MethodPost, "/admin/products", `{"id": "p3", "name": " "}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "name must not be empty")
+}
+
+func TestUpdateProductHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{Products: newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"})}
+	router := setupAdminRouter(app)
+
+	// Test case: Product replaced
+	w := adminRequest(router, http.MethodPut, "/admin/products/p1", `{"name": "Renamed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	pr, _ := app.Products.Get("p1")
+	assert.Equal(t, "Renamed", pr.Name)
+
+	// Test case: Product patched
+	w = adminRequest(router, http.MethodPatch, "/admin/products/p1", `{"name": "Patched"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	pr, _ = app.Products.Get("p1")
+	assert.Equal(t, "Patched", pr.Name)
+
+	// Test case: Product deactivated, the other fields are kept
+	w = adminRequest(router, http.MethodPatch, "/admin/products/p1", `{"active": false, "price": 250, "currency": "EUR"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	pr, _ = app.Products.Get("p1")
+	assert.False(t, pr.IsActive())
+	assert.Equal(t, int64(250), pr.Price)
+	assert.Equal(t, "Patched", pr.Name)
+
+	// Test case: Price without currency
+	w = adminRequest(router, http.MethodPatch, "/admin/products/p1", `{"currency": ""}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test case: Unknown product
+	w = adminRequest(router, http.MethodPut, "/admin/products/invalid", `{"name": "Renamed"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = adminRequest(router, http.MethodPatch, "/admin/products/invalid", `{"name": "Patched"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test case: Mismatching ids
+	w = adminRequest(router, http.MethodPut, "/admin/products/p1", `{"id": "p2", "name": "Renamed"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// slowProducts takes its time to save the products, like a remote db
+type slowProducts struct {
+	*product.MemoryStore
+}
+
+func (s slowProducts) UpdateProduct(ctx context.Context, pr *product.Product) error {
+	time.Sleep(5 * time.Millisecond)
+	return s.MemoryStore.UpdateProduct(ctx, pr)
+}
+
+func TestPatchProductHandlerConcurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := slowProducts{product.NewMemoryStore(&product.Product{ID: "p1", Name: "Product 1"})}
+	catalog, err := product.NewCatalog(context.Background(), store)
+	require.NoError(t, err)
+	app := &Application{Products: catalog}
+	router := setupAdminRouter(app)
+
+	// Test case: concurrent patches of different fields are all kept
+	patches := []string{`{"name": "Patched"}`, `{"category": "Drinks"}`, `{"description": "Cold"}`, `{"image_url": "https://example.com/p1.png"}`}
+	var wg sync.WaitGroup
+	for _, patch := range patches {
+		wg.Add(1)
+		go func(patch string) {
+			defer wg.Done()
+			w := adminRequest(router, http.MethodPatch, "/admin/products/p1", patch)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}(patch)
+	}
+	wg.Wait()
+
+	expected := &product.Product{ID: "p1", Name: "Patched", Category: "Drinks", Description: "Cold", ImageURL: "https://example.com/p1.png"}
+	pr, _ := app.Products.Get("p1")
+	assert.Equal(t, expected, pr)
+	stored, err := store.FetchProducts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, stored["p1"])
+}
+
+func TestDeleteProductHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{Products: newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"})}
+	router := setupAdminRouter(app)
+
+	// Test case: Product deleted
+	w := adminRequest(router, http.MethodDelete, "/admin/products/p1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, app.Products.Len())
+
+	// Test case: Already deleted
+	w = adminRequest(router, http.MethodDelete, "/admin/products/p1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReloadProductsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := product.NewMemoryStore(&product.Product{ID: "p1", Name: "Product 1"})
+	catalog, _ := product.NewCatalog(context.Background(), store)
+	app := &Application{Products: catalog}
+	router := setupAdminRouter(app)
+
+	// a product added directly to the store is not in the catalog until it is reloaded
+	store.CreateProduct(context.Background(), &product.Product{ID: "p2", Name: "Product 2"})
+	_, ok := app.Products.Get("p2")
+	assert.False(t, ok)
+
+	w := adminRequest(router, http.MethodPost, "/admin/products/reload", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, ok = app.Products.Get("p2")
+	assert.True(t, ok)
+}
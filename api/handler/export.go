@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"api_assignment/api/models/vote"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushEvery is the number of votes written between flushes, so the client gets the votes
+// as they are read instead of when the buffers fill up
+const exportFlushEvery = 500
+
+// exportedVote is a vote as written by the export, with the name of its product.
+// Suspicious is always written, so the analyses can tell the votes left out of the avgs
+type exportedVote struct {
+	ProductID   string     `json:"product_id"`
+	ProductName string     `json:"product_name"`
+	SessionID   string     `json:"session_id"`
+	Rate        int        `json:"rate"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Suspicious  bool       `json:"suspicious"`
+}
+
+// voteExporter writes the votes of the export in one format
+type voteExporter interface {
+	contentType() string
+	// begin writes what comes before the first vote, like the csv header
+	begin() error
+	write(v *exportedVote) error
+	// flush writes the buffered votes to the response
+	flush() error
+}
+
+// newVoteExporter returns the exporter of the format writing to w, false for an unknown format
+func newVoteExporter(format string, w io.Writer) (voteExporter, bool) {
+	switch format {
+	case "ndjson":
+		return ndjsonExporter{encoder: json.NewEncoder(w)}, true
+	case "csv":
+		return csvExporter{writer: csv.NewWriter(w)}, true
+	}
+	return nil, false
+}
+
+// ndjsonExporter writes one json vote per line
+type ndjsonExporter struct {
+	encoder *json.Encoder
+}
+
+func (e ndjsonExporter) contentType() string         { return "application/x-ndjson" }
+func (e ndjsonExporter) begin() error                { return nil }
+func (e ndjsonExporter) write(v *exportedVote) error { return e.encoder.Encode(v) }
+func (e ndjsonExporter) flush() error                { return nil }
+
+// csvExporter writes a header row followed by one row per vote
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (e csvExporter) contentType() string { return "text/csv; charset=utf-8" }
+
+func (e csvExporter) begin() error {
+	return e.writer.Write([]string{"product_id", "product_name", "session_id", "rate", "created_at", "updated_at", "suspicious"})
+}
+
+func (e csvExporter) write(v *exportedVote) error {
+	return e.writer.Write([]string{v.ProductID, v.ProductName, v.SessionID, strconv.Itoa(v.Rate),
+		csvTime(v.CreatedAt), csvTime(v.UpdatedAt), strconv.FormatBool(v.Suspicious)})
+}
+
+// csvTime formats the time as RFC 3339 in UTC, empty when unknown
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (e csvExporter) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// @Summary Export all votes
+// @Description Streams every vote, with the name of its product, as NDJSON (one vote per line) or CSV.
+// @Description The votes are written as they are read from the db, so the export does not hold them in memory.
+// @Tags votes
+// @Produce json
+// @Produce text/csv
+// @Param format query string false "ndjson (default) or csv"
+// @Success 200 {array} exportedVote
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /votes/export [get]
+func (app *Application) ExportVotesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "ExportVotesHandler")
+		defer span.End()
+
+		format := c.DefaultQuery("format", "ndjson")
+		exporter, ok := newVoteExporter(format, c.Writer)
+		if !ok {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "format must be ndjson or csv"})
+			return
+		}
+
+		// the response only starts with the first vote, so a failing query can still respond with an error
+		started := false
+		start := func() error {
+			started = true
+			c.Header("Content-Type", exporter.contentType())
+			c.Header("Content-Disposition", "attachment; filename=votes."+format)
+			c.Status(http.StatusOK)
+			return exporter.begin()
+		}
+
+		products := app.Products.Products()
+		written := 0
+		// the request context is cancelled when the client disconnects, which stops reading the votes
+		err := app.voteService.EachVote(c.Request.Context(), func(v *vote.VoteResult) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			exported := &exportedVote{ProductID: v.ProductID, SessionID: v.SessionID, Rate: v.Rate,
+				CreatedAt: v.CreatedAt, UpdatedAt: v.UpdatedAt, Suspicious: v.Suspicious}
+			if pr, ok := products[v.ProductID]; ok {
+				exported.ProductName = pr.Name
+			}
+			if err := exporter.write(exported); err != nil {
+				return err
+			}
+
+			written++
+			if written%exportFlushEvery == 0 {
+				if err := exporter.flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+
+		if err != nil && !started {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("exporting the votes failed", "error", err)
+			return
+		}
+		if err != nil {
+			// the status is sent already, all there is left to do is to stop writing
+			app.log(c).Error("export of the votes stopped", "written", written, "error", err)
+			return
+		}
+
+		// no votes is still a valid export, with the header for csv
+		if !started {
+			if err := start(); err != nil {
+				app.log(c).Error("writing the export failed", "error", err)
+				return
+			}
+		}
+		if err := exporter.flush(); err != nil {
+			app.log(c).Error("writing the export failed", "error", err)
+		}
+	}
+}
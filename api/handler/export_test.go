@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportVotesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	app := &Application{
+		Products: newTestCatalog(&product.Product{ID: "p1", Name: "Product, 1"}),
+		voteService: &MockVoteService{
+			mockAllVotes: []*vote.VoteResult{
+				{ProductID: "p1", SessionID: "s1", Rate: 8, CreatedAt: &createdAt, UpdatedAt: &createdAt},
+				{ProductID: "gone", SessionID: "s2", Rate: 3, Suspicious: true},
+			},
+		},
+	}
+
+	router := setupRouter(app)
+
+	// Test case: ndjson is the default, one vote per line with the product names and the suspicious flag
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/votes/export", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"product_id":"p1","product_name":"Product, 1","session_id":"s1","rate":8,"created_at":"2024-05-01T12:30:00Z","updated_at":"2024-05-01T12:30:00Z","suspicious":false}
+{"product_id":"gone","product_name":"","session_id":"s2","rate":3,"suspicious":true}
+`, w.Body.String())
+
+	// Test case: csv with a header row, fields are quoted when needed
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes/export?format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=votes.csv", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `product_id,product_name,session_id,rate,created_at,updated_at,suspicious
+p1,"Product, 1",s1,8,2024-05-01T12:30:00Z,2024-05-01T12:30:00Z,false
+gone,,s2,3,,,true
+`, w.Body.String())
+
+	// Test case: no votes is an empty export
+	app.voteService = &MockVoteService{}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes/export?format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "product_id,product_name,session_id,rate,created_at,updated_at,suspicious\n", w.Body.String())
+
+	// Test case: unknown format
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes/export?format=xml", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test case: the store fails before the first vote
+	app.voteService = &MockVoteService{mockError: errors.New("db down")}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes/export", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
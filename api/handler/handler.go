@@ -1,15 +1,23 @@
 package handler
 
 import (
+	"api_assignment/api/middleware"
 	"api_assignment/api/models/product"
 	"api_assignment/api/models/vote"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // @title Product Voting API
@@ -21,76 +29,137 @@ import (
 // Application is the handler for the requests. Additionally, it holds the necessary field for the whole Application
 // this includes the voteService and the products.
 // Products are saved here to be used for request validation and to return them when /products is called
-// purpose of saving them here instead of db is becuase products do not change frequently and to reduce calls to db
+// purpose of saving them here instead of db is becuase products do not change frequently and to reduce calls to db.
+// The catalog writes the changes done through the admin endpoints to the db as well
 type Application struct {
-	Products map[string]*product.Product
+	Products *product.Catalog
 
-	// interface for easier testing
-	voteService interface {
-		AllVotes() ([]*vote.VoteResult, error)
-		PostVote(newVote *vote.VoteResult) (*bool, error)
-		GetVotesBySessionID(sessionID string) ([]*vote.VoteResult, error)
-		GetVotesByProductID(productID string) ([]*vote.VoteResult, error)
-		GetAverageVotesForAllProducts(products map[string]*product.Product) (map[string]*vote.ProductVote, error)
-	}
+	// interface for easier testing and for swapping the storage backend
+	voteService vote.Store
+
+	// Ranking holds the default options of /products/ranking, the query params override them
+	Ranking vote.RankingOptions
+
+	// Logger logs the errors of the handlers, slog.Default() unless replaced
+	Logger *slog.Logger
 }
 
-// NewApp creates an istancve of the application and assigns the client passed to it as its client
-func NewApp(client *mongo.Client) *Application {
-	prs, err := product.FetchProducts(client)
+// NewApp creates an istancve of the application backed by the passed vote and product stores
+func NewApp(votes vote.Store, products product.Store) *Application {
+	prs, err := product.NewCatalog(context.Background(), products)
 	if err != nil {
 		panic(err)
 	}
 	return &Application{
 		Products:    prs,
-		voteService: vote.VoteModel{DB: client},
+		voteService: votes,
+		Logger:      slog.Default(),
 	}
 
 }
 
+// log returns the logger of the app with the ids of the request and of its trace
+func (app *Application) log(c *gin.Context) *slog.Logger {
+	logger := app.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if requestID := middleware.RequestID(c); requestID != "" {
+		logger = logger.With(middleware.RequestIDKey, requestID)
+	}
+	if traceID := middleware.TraceID(c); traceID != "" {
+		logger = logger.With(middleware.TraceIDKey, traceID)
+	}
+	return logger
+}
+
+// tracer starts the spans of the handlers
+var tracer = otel.Tracer("api_assignment/api/handler")
+
+// startSpan starts the span of the handler, a child of the span of the request.
+// The request carries it from then on, so the calls to the stores made with c.Request.Context() are its children
+func startSpan(c *gin.Context, handler string) trace.Span {
+	ctx, span := tracer.Start(c.Request.Context(), handler)
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
 // @Summary Get all products
-// @Description Retrieves all the available products in the system.
+// @Description Retrieves all the available products in the system, optionally filtered by category and active status.
 // @Tags products
 // @Accept json
 // @Produce json
+// @Param category query string false "Only products of the category"
+// @Param active query bool false "Only active (true) or inactive (false) products"
 // @Success 200 {object} map[string]*product.Product
 // @Failure 204 {object} map[string]string
+// @Failure 400 {object} map[string]string
 // @Router /products [get]
 func (app *Application) AllProductsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "AllProductsHandler")
+		defer span.End()
+
+		products := app.Products.Products()
+
+		category, filterCategory := c.GetQuery("category")
+		active, filterActive := c.GetQuery("active")
+		if filterCategory || filterActive {
+			wantActive, err := strconv.ParseBool(active)
+			if filterActive && err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "active must be true or false"})
+				return
+			}
+
+			filtered := make(map[string]*product.Product)
+			for id, pr := range products {
+				if filterCategory && !strings.EqualFold(pr.Category, category) {
+					continue
+				}
+				if filterActive && pr.IsActive() != wantActive {
+					continue
+				}
+				filtered[id] = pr
+			}
+			products = filtered
+		}
 
-		if len(app.Products) == 0 {
+		if len(products) == 0 {
 			c.IndentedJSON(http.StatusOK, gin.H{"message": "Looks like there are no products so far."})
 			return
 		}
 
-		c.IndentedJSON(http.StatusOK, app.Products)
+		c.IndentedJSON(http.StatusOK, products)
 	}
 }
 
 // @Summary Get all votes
-// @Description Retrieves all the votes from the system.
+// @Description Retrieves the votes from the system, a page at a time.
 // @Tags votes
 // @Accept json
 // @Produce json
+// @Param limit query int false "Votes per page, 100 by default and at most 1000"
+// @Param cursor query string false "The X-Next-Cursor header of the previous page"
+// @Param sort query string false "time (default) or rate"
+// @Param order query string false "asc (default) or desc"
+// @Param min_rate query int false "Lowest rate to include"
+// @Param max_rate query int false "Highest rate to include"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, missing on the last page"
 // @Success 200 {array} vote.VoteResult
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /votes [get]
 func (app *Application) AllVotessHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "AllVotessHandler")
+		defer span.End()
 
-		allVotes, err := app.voteService.AllVotes()
-		if err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
-			fmt.Fprintln(os.Stderr, err.Error())
-			return
-		}
-		if len(allVotes) == 0 {
-			c.IndentedJSON(http.StatusOK, gin.H{"message": "Looks like there are no votes so far."})
+		opts, ok := parseListOptions(c)
+		if !ok {
 			return
 		}
 
-		c.IndentedJSON(http.StatusOK, allVotes)
+		app.respondVotesPage(c, opts, "Looks like there are no votes so far.")
 	}
 }
 
@@ -107,20 +176,28 @@ func (app *Application) AllVotessHandler() gin.HandlerFunc {
 // @Router /votes [post]
 func (app *Application) PostVoteHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "PostVoteHandler")
+		defer span.End()
 
 		newVote := &vote.VoteResult{}
 		if err := c.ShouldBindJSON(newVote); err != nil {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "request is invalid. Please check your request"})
-			fmt.Fprintln(os.Stderr, err.Error())
+			app.log(c).Debug("invalid request body", "error", err)
 			return
 		}
 
 		// could not find the product
-		if _, ok := app.Products[newVote.ProductID]; !ok {
+		votedProduct, ok := app.Products.Get(newVote.ProductID)
+		if !ok {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
 			return
 		}
 
+		if !votedProduct.IsActive() {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "This product is not available for voting"})
+			return
+		}
+
 		if newVote.Rate <= 0 || newVote.Rate > 10 {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "rate must be between 0 and 10!"})
 			return
@@ -130,12 +207,14 @@ func (app *Application) PostVoteHandler() gin.HandlerFunc {
 		// Check if the session ID exists
 		sessionID := session.Get("session_id").(string)
 		newVote.SessionID = sessionID
+		// only the vote guard flags votes, never the request body
+		newVote.Suspicious = c.GetBool(middleware.SuspiciousVoteKey)
 
-		voteExists, err := app.voteService.PostVote(newVote)
+		voteExists, err := app.voteService.PostVote(c.Request.Context(), newVote)
 
 		if err != nil {
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
-			fmt.Println(err.Error())
+			app.log(c).Error("posting the vote failed", "product_id", newVote.ProductID, "error", err)
 			return
 		}
 		// if vote already exists update it
@@ -148,69 +227,182 @@ func (app *Application) PostVoteHandler() gin.HandlerFunc {
 	}
 }
 
+// @Summary Delete a vote
+// @Description Withdraws the vote of the caller's session for a product.
+// @Tags votes
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /votes/product/{id} [delete]
+func (app *Application) DeleteVoteHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "DeleteVoteHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		session := sessions.Default(c)
+		sessionID := session.Get("session_id").(string)
+
+		// the product may have been removed from the catalog since, the vote can still be withdrawn
+		err := app.voteService.DeleteVote(c.Request.Context(), productID, sessionID)
+		if errors.Is(err, vote.ErrNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "You have not voted for this product"})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("deleting the vote failed", "product_id", productID, "error", err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Your vote has been deleted"})
+	}
+}
+
+// @Summary Get your votes
+// @Description Retrieves the votes of the caller's session, a page at a time.
+// @Tags votes
+// @Accept json
+// @Produce json
+// @Param limit query int false "Votes per page, 100 by default and at most 1000"
+// @Param cursor query string false "The X-Next-Cursor header of the previous page"
+// @Param sort query string false "time (default) or rate"
+// @Param order query string false "asc (default) or desc"
+// @Param min_rate query int false "Lowest rate to include"
+// @Param max_rate query int false "Highest rate to include"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, missing on the last page"
+// @Success 200 {array} vote.VoteResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/votes [get]
+func (app *Application) GetMyVotesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "GetMyVotesHandler")
+		defer span.End()
+
+		opts, ok := parseListOptions(c)
+		if !ok {
+			return
+		}
+		session := sessions.Default(c)
+		opts.SessionID = session.Get("session_id").(string)
+
+		app.respondVotesPage(c, opts, "Looks like you have not voted so far.")
+	}
+}
+
 // @Summary Get votes by session ID
-// @Description Retrieves all votes for a given session ID.
+// @Description Retrieves the votes for a given session ID, a page at a time.
 // @Tags votes
 // @Accept json
 // @Produce json
 // @Param id path string true "Session ID"
+// @Param limit query int false "Votes per page, 100 by default and at most 1000"
+// @Param cursor query string false "The X-Next-Cursor header of the previous page"
+// @Param sort query string false "time (default) or rate"
+// @Param order query string false "asc (default) or desc"
+// @Param min_rate query int false "Lowest rate to include"
+// @Param max_rate query int false "Highest rate to include"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, missing on the last page"
 // @Success 200 {array} vote.VoteResult
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /votes/session/{id} [get]
 func (app *Application) GetVotesBySessionIDHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "GetVotesBySessionIDHandler")
+		defer span.End()
 
-		sessionID := c.Param("id")
-
-		votes, err := app.voteService.GetVotesBySessionID(sessionID)
-		if err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
-			fmt.Fprintln(os.Stderr, err.Error())
-			return
-		}
-		if len(votes) == 0 {
-			c.IndentedJSON(http.StatusOK, gin.H{"message": "Looks like there are no votes for this session so far."})
+		opts, ok := parseListOptions(c)
+		if !ok {
 			return
 		}
+		opts.SessionID = c.Param("id")
 
-		c.IndentedJSON(http.StatusOK, votes)
+		app.respondVotesPage(c, opts, "Looks like there are no votes for this session so far.")
 
 	}
 }
 
 // @Summary Get votes by product ID
-// @Description Retrieves all votes for a given product ID.
+// @Description Retrieves the votes for a given product ID, a page at a time.
 // @Tags votes
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param limit query int false "Votes per page, 100 by default and at most 1000"
+// @Param cursor query string false "The X-Next-Cursor header of the previous page"
+// @Param sort query string false "time (default) or rate"
+// @Param order query string false "asc (default) or desc"
+// @Param min_rate query int false "Lowest rate to include"
+// @Param max_rate query int false "Highest rate to include"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, missing on the last page"
 // @Success 200 {array} vote.VoteResult
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /votes/product/{id} [get]
 func (app *Application) GetVotesByProductIDHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "GetVotesByProductIDHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		if _, ok := app.Products.Get(productID); !ok {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
+			return
+		}
+
+		opts, ok := parseListOptions(c)
+		if !ok {
+			return
+		}
+		opts.ProductID = productID
+
+		app.respondVotesPage(c, opts, "Looks like there are no votes for this product so far.")
+
+	}
+}
+
+// @Summary Get the vote history of a product
+// @Description Retrieves every change of the votes for a given product ID, oldest first.
+// @Description old_rate is null when the vote was first cast.
+// @Tags votes
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {array} vote.VoteChange
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /votes/product/{id}/history [get]
+func (app *Application) GetVoteHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "GetVoteHistoryHandler")
+		defer span.End()
 
 		productID := c.Param("id")
 
-		if _, ok := app.Products[productID]; !ok {
+		if _, ok := app.Products.Get(productID); !ok {
 			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
 			return
 		}
 
-		votes, err := app.voteService.GetVotesByProductID(productID)
+		changes, err := app.voteService.GetVoteHistory(c.Request.Context(), productID)
 		if err != nil {
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
-			fmt.Fprintln(os.Stderr, err.Error())
+			app.log(c).Error("getting the vote history failed", "product_id", productID, "error", err)
 			return
 		}
-		if len(votes) == 0 {
+		if len(changes) == 0 {
 			c.IndentedJSON(http.StatusOK, gin.H{"message": "Looks like there are no votes for this product so far."})
 			return
 		}
 
-		c.IndentedJSON(http.StatusOK, votes)
-
+		c.IndentedJSON(http.StatusOK, changes)
 	}
 }
 
@@ -224,11 +416,14 @@ func (app *Application) GetVotesByProductIDHandler() gin.HandlerFunc {
 // @Router /products/avgs [get]
 func (app *Application) GetAverageVotesForAllProductsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		span := startSpan(c, "GetAverageVotesForAllProductsHandler")
+		defer span.End()
 
-		avgs, err := app.voteService.GetAverageVotesForAllProducts(app.Products)
+		products := app.Products.Products()
+		avgs, err := app.voteService.GetAverageVotesForAllProducts(c.Request.Context(), products)
 		if err != nil {
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
-			fmt.Fprintln(os.Stderr, err.Error())
+			app.log(c).Error("computing the average votes failed", "error", err)
 			return
 		}
 		if len(avgs) == 0 {
@@ -240,3 +435,266 @@ func (app *Application) GetAverageVotesForAllProductsHandler() gin.HandlerFunc {
 
 	}
 }
+
+// @Summary Get the rating distribution of a product
+// @Description Retrieves the histogram of the rates 1-10 of a product along with its avg, median, standard deviation, min, max and percentage of positive votes.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} vote.ProductStats
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/stats [get]
+func (app *Application) GetProductStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "GetProductStatsHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		if _, ok := app.Products.Get(productID); !ok {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
+			return
+		}
+
+		votes, err := app.voteService.GetVotesByProductID(c.Request.Context(), productID)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("getting the votes of the product failed", "product_id", productID, "error", err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, vote.ComputeStats(productID, votes))
+
+	}
+}
+
+// @Summary Get the rating trend of a product
+// @Description Retrieves the avg rate and number of votes of a product per hour, day or week, by the time the votes were first cast.
+// @Description Buckets without votes are left out.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param bucket query string false "hour, day (default) or week; weeks start on monday"
+// @Param from query string false "Only votes cast at or after, RFC 3339 or YYYY-MM-DD (UTC)"
+// @Param to query string false "Only votes cast before, RFC 3339 or YYYY-MM-DD (UTC)"
+// @Success 200 {object} vote.ProductTrend
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/trend [get]
+func (app *Application) GetProductTrendHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "GetProductTrendHandler")
+		defer span.End()
+
+		productID := c.Param("id")
+
+		if _, ok := app.Products.Get(productID); !ok {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No such product"})
+			return
+		}
+
+		opts := vote.TrendOptions{Bucket: c.DefaultQuery("bucket", vote.BucketDay)}
+		if opts.Bucket != vote.BucketHour && opts.Bucket != vote.BucketDay && opts.Bucket != vote.BucketWeek {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "bucket must be hour, day or week"})
+			return
+		}
+		var err error
+		if opts.From, err = parseTime(c.Query("from")); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "from must be an RFC 3339 time or a YYYY-MM-DD date"})
+			return
+		}
+		if opts.To, err = parseTime(c.Query("to")); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "to must be an RFC 3339 time or a YYYY-MM-DD date"})
+			return
+		}
+		if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "from must be before to"})
+			return
+		}
+
+		trend, err := app.voteService.GetProductTrend(c.Request.Context(), productID, opts)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("computing the trend failed", "product_id", productID, "error", err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, trend)
+	}
+}
+
+// @Summary Get the products ranking
+// @Description Orders the products by a bayesian average or by the wilson lower bound of their normalized rates, so products with few votes don't outrank products with many votes.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param method query string false "bayesian (default) or wilson"
+// @Param prior_mean query number false "Prior mean of the bayesian average, the mean of all votes by default"
+// @Param prior_weight query number false "Number of votes the prior mean is worth"
+// @Param page query int false "Page, starting at 1"
+// @Param page_size query int false "Products per page, at most 100"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/ranking [get]
+func (app *Application) GetProductsRankingHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := startSpan(c, "GetProductsRankingHandler")
+		defer span.End()
+
+		opts := app.Ranking
+		if method := c.Query("method"); method != "" {
+			opts.Method = method
+		}
+		if opts.Method == "" {
+			opts.Method = vote.RankingBayesian
+		}
+		if opts.Method != vote.RankingBayesian && opts.Method != vote.RankingWilson {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "method must be bayesian or wilson"})
+			return
+		}
+
+		var err error
+		if priorMean := c.Query("prior_mean"); priorMean != "" {
+			if opts.PriorMean, err = strconv.ParseFloat(priorMean, 64); err != nil || !(opts.PriorMean >= 1 && opts.PriorMean <= 10) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "prior_mean must be a number between 1 and 10"})
+				return
+			}
+		}
+		if priorWeight := c.Query("prior_weight"); priorWeight != "" {
+			if opts.PriorWeight, err = strconv.ParseFloat(priorWeight, 64); err != nil || !(opts.PriorWeight > 0) || math.IsInf(opts.PriorWeight, 1) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "prior_weight must be a positive number"})
+				return
+			}
+		}
+
+		page, pageSize, ok := parsePage(c)
+		if !ok {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "page must be positive and page_size between 1 and 100"})
+			return
+		}
+
+		products := app.Products.Products()
+		avgs, err := app.voteService.GetAverageVotesForAllProducts(c.Request.Context(), products)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			app.log(c).Error("computing the average votes failed", "error", err)
+			return
+		}
+
+		ranked := vote.RankProducts(products, avgs, opts)
+
+		start := min((page-1)*pageSize, len(ranked))
+		end := min(start+pageSize, len(ranked))
+
+		c.IndentedJSON(http.StatusOK, gin.H{
+			"method":    opts.Method,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     len(ranked),
+			"products":  ranked[start:end],
+		})
+
+	}
+}
+
+// parsePage reads the page and page_size query params, defaulting to the first page of 20 items
+func parsePage(c *gin.Context) (page, pageSize int, ok bool) {
+	page, pageSize = 1, 20
+
+	var err error
+	if p := c.Query("page"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			return 0, 0, false
+		}
+	}
+	if ps := c.Query("page_size"); ps != "" {
+		if pageSize, err = strconv.Atoi(ps); err != nil || pageSize < 1 || pageSize > 100 {
+			return 0, 0, false
+		}
+	}
+	return page, pageSize, true
+}
+
+// parseListOptions reads the pagination, sorting and filtering query params of the vote listings.
+// It responds with 400 and returns false when they are invalid
+func parseListOptions(c *gin.Context) (vote.ListOptions, bool) {
+	opts := vote.ListOptions{
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	invalid := func(message string) (vote.ListOptions, bool) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": message})
+		return opts, false
+	}
+
+	if opts.Sort != "" && opts.Sort != vote.SortTime && opts.Sort != vote.SortRate {
+		return invalid("sort must be time or rate")
+	}
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return invalid("order must be asc or desc")
+	}
+
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 1 || opts.Limit > vote.MaxListLimit {
+			return invalid(fmt.Sprintf("limit must be between 1 and %d", vote.MaxListLimit))
+		}
+	}
+	if minRate := c.Query("min_rate"); minRate != "" {
+		if opts.MinRate, err = strconv.Atoi(minRate); err != nil || opts.MinRate < 1 || opts.MinRate > 10 {
+			return invalid("min_rate must be between 1 and 10")
+		}
+	}
+	if maxRate := c.Query("max_rate"); maxRate != "" {
+		if opts.MaxRate, err = strconv.Atoi(maxRate); err != nil || opts.MaxRate < 1 || opts.MaxRate > 10 {
+			return invalid("max_rate must be between 1 and 10")
+		}
+	}
+	return opts, true
+}
+
+// respondVotesPage fetches the page of votes of the options and responds with it.
+// The cursor of the next page is passed in the X-Next-Cursor header, so the body stays a list of votes
+func (app *Application) respondVotesPage(c *gin.Context, opts vote.ListOptions, emptyMessage string) {
+	page, err := app.voteService.ListVotes(c.Request.Context(), opts)
+	if errors.Is(err, vote.ErrInvalidCursor) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "cursor is invalid, it must be the X-Next-Cursor of a previous page with the same sort and order"})
+		return
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+		app.log(c).Error("listing the votes failed", "error", err)
+		return
+	}
+
+	if page.Next != "" {
+		c.Header("X-Next-Cursor", page.Next)
+	}
+	if len(page.Votes) == 0 {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": emptyMessage})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, page.Votes)
+}
+
+// parseTime parses an RFC 3339 time or a date in UTC, the zero time when empty
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
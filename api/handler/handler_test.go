@@ -4,18 +4,34 @@ import (
 	"api_assignment/api/middleware"
 	"api_assignment/api/models/product"
 	"api_assignment/api/models/vote"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestCatalog creates a catalog over an in-memory store holding the products
+func newTestCatalog(products ...*product.Product) *product.Catalog {
+	catalog, err := product.NewCatalog(context.Background(), product.NewMemoryStore(products...))
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
 // setupRouter sets up test router
 func setupRouter(app *Application) *gin.Engine {
 	router := gin.Default()
@@ -29,8 +45,15 @@ func setupRouter(app *Application) *gin.Engine {
 	router.GET("/votes", app.AllVotessHandler())
 	router.POST("/votes", app.PostVoteHandler())
 	router.GET("/votes/session/:id", app.GetVotesBySessionIDHandler())
+	router.GET("/me/votes", app.GetMyVotesHandler())
+	router.GET("/votes/export", app.ExportVotesHandler())
 	router.GET("/votes/product/:id", app.GetVotesByProductIDHandler())
+	router.DELETE("/votes/product/:id", app.DeleteVoteHandler())
+	router.GET("/votes/product/:id/history", app.GetVoteHistoryHandler())
 	router.GET("/products/avgs", app.GetAverageVotesForAllProductsHandler())
+	router.GET("/products/:id/stats", app.GetProductStatsHandler())
+	router.GET("/products/:id/trend", app.GetProductTrendHandler())
+	router.GET("/products/ranking", app.GetProductsRankingHandler())
 	return router
 }
 
@@ -41,7 +64,7 @@ func TestAllProductsHandler(t *testing.T) {
 		"p1": {ID: "p1", Name: "Product 1"},
 	}
 	app := &Application{
-		Products:    products,
+		Products:    newTestCatalog(products["p1"]),
 		voteService: &MockVoteService{},
 	}
 
@@ -59,7 +82,7 @@ func TestAllProductsHandler(t *testing.T) {
 	assert.Equal(t, products, respProducts)
 
 	// Test case: No products
-	app.Products = map[string]*product.Product{}
+	app.Products = newTestCatalog()
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest(http.MethodGet, "/products", nil)
 	router.ServeHTTP(w, req)
@@ -107,9 +130,9 @@ func TestPostVoteHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	app := &Application{
-		Products: map[string]*product.Product{
-			"p1": {ID: "p1", Name: "Product 1"},
-		},
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1"},
+		),
 		voteService: &MockVoteService{
 			mockPostVoteExists: func() *bool { v := false; return &v }(),
 		},
@@ -195,9 +218,9 @@ func TestGetVotesByProductIDHandler(t *testing.T) {
 		{ProductID: "p1", SessionID: "s1", Rate: 8},
 	}
 	app := &Application{
-		Products: map[string]*product.Product{
-			"p1": {ID: "p1", Name: "Product 1"},
-		},
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1"},
+		),
 		voteService: &MockVoteService{
 			mockGetVotesByProduct: mockVotes,
 		},
@@ -232,9 +255,9 @@ func TestGetAverageVotesForAllProductsHandler(t *testing.T) {
 		"p1": {VotesCount: 2, Avg: 7.5},
 	}
 	app := &Application{
-		Products: map[string]*product.Product{
-			"p1": {ID: "p1", Name: "Product 1"},
-		},
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1"},
+		),
 		voteService: &MockVoteService{
 			mockAvgVotes: mockAvgVotes,
 		},
@@ -262,3 +285,497 @@ func TestGetAverageVotesForAllProductsHandler(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Looks like there are no votes so far.")
 }
+
+func TestGetProductStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1"},
+		),
+		voteService: &MockVoteService{
+			mockGetVotesByProduct: []*vote.VoteResult{
+				{ProductID: "p1", SessionID: "s1", Rate: 4},
+				{ProductID: "p1", SessionID: "s2", Rate: 8},
+			},
+		},
+	}
+
+	router := setupRouter(app)
+
+	// Test case: Stats of the product votes
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/products/p1/stats", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var respStats vote.ProductStats
+	err := json.Unmarshal(w.Body.Bytes(), &respStats)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, respStats.VotesCount)
+	assert.Equal(t, [10]int{0, 0, 0, 1, 0, 0, 0, 1, 0, 0}, respStats.Histogram)
+	assert.Equal(t, 6.0, respStats.Median)
+	assert.Equal(t, 50.0, respStats.PositivePercentage)
+
+	// Test case: Invalid product ID
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/products/invalid/stats", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "No such product")
+}
+
+func TestGetProductsRankingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1"},
+			&product.Product{ID: "p2", Name: "Product 2"},
+			&product.Product{ID: "p3", Name: "Product 3"},
+		),
+		voteService: &MockVoteService{
+			mockAvgVotes: map[string]*vote.ProductVote{
+				"p1": {Avg: 10, VotesCount: 1},
+				"p2": {Avg: 9.2, VotesCount: 500},
+				"p3": {Avg: 2, VotesCount: 100},
+			},
+		},
+	}
+
+	router := setupRouter(app)
+
+	// Test case: Products ranked and paginated
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/products/ranking?method=wilson&page=1&page_size=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Total    int                   `json:"total"`
+		Products []*vote.RankedProduct `json:"products"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, resp.Total)
+	assert.Len(t, resp.Products, 2)
+	assert.Equal(t, "p2", resp.Products[0].ProductID)
+
+	// Test case: Invalid method
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/products/ranking?method=avg", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAllProductsHandlerFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inactive := false
+	app := &Application{
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Red Bull", Category: "drinks"},
+			&product.Product{ID: "p2", Name: "Brownie", Category: "snacks"},
+			&product.Product{ID: "p3", Name: "Old Cola", Category: "drinks", Active: &inactive},
+		),
+		voteService: &MockVoteService{},
+	}
+
+	router := setupRouter(app)
+
+	filter := func(query string) map[string]*product.Product {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/products?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var respProducts map[string]*product.Product
+		json.Unmarshal(w.Body.Bytes(), &respProducts)
+		return respProducts
+	}
+
+	// Test case: By category
+	assert.Len(t, filter("category=Drinks"), 2)
+
+	// Test case: By category and active status
+	respProducts := filter("category=drinks&active=true")
+	assert.Len(t, respProducts, 1)
+	assert.Contains(t, respProducts, "p1")
+
+	// Test case: Inactive products
+	respProducts = filter("active=false")
+	assert.Len(t, respProducts, 1)
+	assert.Contains(t, respProducts, "p3")
+
+	// Test case: Invalid active
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/products?active=maybe", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPostVoteHandlerSuspicious(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	voteService := &MockVoteService{
+		mockPostVoteExists: func() *bool { v := false; return &v }(),
+	}
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: voteService,
+	}
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session_cookie", cookie.NewStore([]byte("secret"))))
+	router.Use(middleware.CheckSession())
+	router.POST("/votes", app.PostVoteHandler())
+	router.POST("/flagged/votes", func(c *gin.Context) { c.Set(middleware.SuspiciousVoteKey, true) }, app.PostVoteHandler())
+
+	// Test case: clients can't flag their votes themselves
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/votes", strings.NewReader(`{"product_id": "p1", "rate": 8, "suspicious": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, voteService.lastPostedVote.Suspicious)
+
+	// Test case: the votes flagged by the vote guard are still received
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/flagged/votes", strings.NewReader(`{"product_id": "p1", "rate": 8}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, voteService.lastPostedVote.Suspicious)
+}
+
+// failingSessions is a session store whose backend is down
+type failingSessions struct {
+	sessions.Store
+}
+
+func (s failingSessions) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+func (s failingSessions) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	session.Options = &gsessions.Options{Path: "/"}
+	session.IsNew = true
+	return session, nil
+}
+
+func (failingSessions) Save(*http.Request, http.ResponseWriter, *gsessions.Session) error {
+	return errors.New("the session could not be loaded, it is not saved")
+}
+
+func TestPostVoteHandlerSessionStoreDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	voteService := &MockVoteService{
+		mockPostVoteExists: func() *bool { v := false; return &v }(),
+	}
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: voteService,
+	}
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session_cookie", failingSessions{cookie.NewStore([]byte("secret"))}))
+	router.Use(middleware.CheckSession())
+	router.POST("/votes", app.PostVoteHandler())
+
+	// Test case: the vote is rejected rather than saved under a session nobody keeps
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/votes", strings.NewReader(`{"product_id": "p1", "rate": 8}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Nil(t, voteService.lastPostedVote)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestPostVoteHandlerFirstVoteCounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := NewApp(vote.NewMemoryStore(), product.NewMemoryStore(&product.Product{ID: "p1", Name: "Product 1"}))
+	guard := middleware.NewVoteGuard(middleware.VoteGuardConfig{})
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session_cookie", cookie.NewStore([]byte("secret"))))
+	router.Use(guard.TrackNewSessions())
+	router.Use(middleware.CheckSession())
+	router.POST("/votes", guard.LimitVotes(), app.PostVoteHandler())
+	router.GET("/products/avgs", app.GetAverageVotesForAllProductsHandler())
+
+	// Test case: the first vote of a client without a cookie counts in the averages
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/votes", strings.NewReader(`{"product_id": "p1", "rate": 8}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/products/avgs", nil)
+	router.ServeHTTP(w, req)
+	avgs := map[string]*vote.ProductVote{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &avgs))
+	if assert.Contains(t, avgs, "p1") {
+		assert.Equal(t, 1, avgs["p1"].VotesCount)
+		assert.Equal(t, 8.0, avgs["p1"].Avg)
+	}
+}
+
+func TestPostVoteHandlerInactiveProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inactive := false
+	app := &Application{
+		Products: newTestCatalog(
+			&product.Product{ID: "p1", Name: "Product 1", Active: &inactive},
+		),
+		voteService: &MockVoteService{
+			mockPostVoteExists: func() *bool { v := false; return &v }(),
+		},
+	}
+
+	router := setupRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/votes", strings.NewReader(`{"product_id": "p1", "rate": 8}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "This product is not available for voting")
+}
+
+func TestVoteListingPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockVoteService{
+		mockGetVotesBySession: []*vote.VoteResult{{ProductID: "p1", SessionID: "s1", Rate: 8}},
+		mockNextCursor:        "next-page",
+	}
+	app := &Application{voteService: mockService}
+
+	router := setupRouter(app)
+
+	// Test case: options are passed to the store and the next cursor is in the header
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/votes/session/s1?limit=1&sort=rate&order=desc&min_rate=3&max_rate=9&cursor=abc", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "next-page", w.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, vote.ListOptions{
+		SessionID: "s1", Limit: 1, Sort: vote.SortRate, Desc: true, MinRate: 3, MaxRate: 9, Cursor: "abc",
+	}, mockService.lastListOptions)
+
+	// Test case: invalid params
+	for _, query := range []string{"limit=0", "limit=1001", "sort=name", "order=up", "min_rate=0", "max_rate=11", "min_rate=x"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/votes?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Test case: invalid cursor
+	app.voteService = &MockVoteService{mockError: vote.ErrInvalidCursor}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes?cursor=abc", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cursor is invalid")
+}
+
+func TestGetVoteHistoryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	oldRate := 4
+	changedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	mockHistory := []*vote.VoteChange{
+		{ProductID: "p1", SessionID: "s1", NewRate: 4, ChangedAt: changedAt},
+		{ProductID: "p1", SessionID: "s1", OldRate: &oldRate, NewRate: 9, ChangedAt: changedAt.Add(time.Hour)},
+	}
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: &MockVoteService{mockHistory: mockHistory},
+	}
+
+	router := setupRouter(app)
+
+	// Test case: changes of the product
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/votes/product/p1/history", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var respHistory []*vote.VoteChange
+	err := json.Unmarshal(w.Body.Bytes(), &respHistory)
+	assert.NoError(t, err)
+	assert.Equal(t, mockHistory, respHistory)
+	assert.Contains(t, w.Body.String(), `"old_rate": null`)
+
+	// Test case: unknown product
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/votes/product/invalid/history", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetProductTrendHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockTrend := &vote.ProductTrend{ProductID: "p1", Bucket: vote.BucketDay, Buckets: []*vote.TrendBucket{
+		{Start: day, Avg: 7.5, Count: 2},
+	}}
+	mockService := &MockVoteService{mockTrend: mockTrend}
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: mockService,
+	}
+
+	router := setupRouter(app)
+
+	// Test case: trend of the product, dates and times are accepted
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/products/p1/trend?bucket=week&from=2024-05-01&to=2024-06-01T12:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var respTrend *vote.ProductTrend
+	err := json.Unmarshal(w.Body.Bytes(), &respTrend)
+	assert.NoError(t, err)
+	assert.Equal(t, mockTrend, respTrend)
+	assert.Equal(t, vote.TrendOptions{
+		Bucket: vote.BucketWeek,
+		From:   day,
+		To:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}, mockService.lastTrendOptions)
+
+	// Test case: invalid params
+	for _, query := range []string{"bucket=month", "from=yesterday", "to=2024-13-01", "from=2024-05-02&to=2024-05-01"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/products/p1/trend?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Test case: unknown product
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/products/invalid/trend", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteVoteHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: &MockVoteService{},
+	}
+
+	router := setupRouter(app)
+
+	// Test case: the vote is deleted
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/votes/product/p1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Your vote has been deleted")
+
+	// Test case: no vote of the session
+	app.voteService = &MockVoteService{mockError: vote.ErrNotFound}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/votes/product/p1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test case: store error
+	app.voteService = &MockVoteService{mockError: errors.New("db down")}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/votes/product/p1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetMyVotesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockVoteService{
+		mockGetVotesBySession: []*vote.VoteResult{{ProductID: "p1", SessionID: "s1", Rate: 8}},
+	}
+	app := &Application{voteService: mockService}
+
+	router := setupRouter(app)
+
+	// Test case: the votes of the session of the cookie
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/me/votes?sort=rate", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, mockService.lastListOptions.SessionID)
+	assert.Equal(t, vote.SortRate, mockService.lastListOptions.Sort)
+
+	// Test case: the same cookie keeps the same session
+	sessionID := mockService.lastListOptions.SessionID
+	w2 := httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/me/votes", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(w2, req)
+
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, sessionID, mockService.lastListOptions.SessionID)
+
+	// Test case: no votes
+	app.voteService = &MockVoteService{}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/me/votes", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Looks like you have not voted so far.")
+}
+
+func TestHandlerErrorsAreLogged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	app := &Application{
+		Products:    newTestCatalog(&product.Product{ID: "p1", Name: "Product 1"}),
+		voteService: &MockVoteService{mockError: errors.New("db down")},
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+	}
+
+	router := gin.New()
+	router.Use(middleware.Log(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	router.GET("/products/avgs", app.GetAverageVotesForAllProductsHandler())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/products/avgs", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+	router.ServeHTTP(w, req)
+
+	// Test case: the error is logged by the logger of the app, with the id of the request
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	line := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "db down", line["error"])
+	assert.Equal(t, "abc-123", line[middleware.RequestIDKey])
+}
@@ -3,49 +3,108 @@ package handler
 import (
 	"api_assignment/api/models/product"
 	"api_assignment/api/models/vote"
+	"context"
 )
 
-// MockVoteService is a mock implementation of the voteService interface
+// MockVoteService is a mock implementation of the vote.Store interface
 type MockVoteService struct {
 	mockAllVotes          []*vote.VoteResult
 	mockGetVotesBySession []*vote.VoteResult
 	mockGetVotesByProduct []*vote.VoteResult
 	mockPostVoteExists    *bool
 	mockAvgVotes          map[string]*vote.ProductVote
+	mockNextCursor        string
+	mockHistory           []*vote.VoteChange
+	mockTrend             *vote.ProductTrend
 	mockError             error
+	// lastListOptions are the options of the last ListVotes call
+	lastListOptions vote.ListOptions
+	// lastTrendOptions are the options of the last GetProductTrend call
+	lastTrendOptions vote.TrendOptions
+	// lastPostedVote is the vote of the last PostVote call
+	lastPostedVote *vote.VoteResult
 }
 
-func (m *MockVoteService) AllVotes() ([]*vote.VoteResult, error) {
+func (m *MockVoteService) AllVotes(ctx context.Context) ([]*vote.VoteResult, error) {
 	if m.mockError != nil {
 		return nil, m.mockError
 	}
 	return m.mockAllVotes, nil
 }
 
-func (m *MockVoteService) PostVote(newVote *vote.VoteResult) (*bool, error) {
+func (m *MockVoteService) PostVote(ctx context.Context, newVote *vote.VoteResult) (*bool, error) {
+	m.lastPostedVote = newVote
 	if m.mockError != nil {
 		return nil, m.mockError
 	}
 	return m.mockPostVoteExists, nil
 }
 
-func (m *MockVoteService) GetVotesBySessionID(sessionID string) ([]*vote.VoteResult, error) {
+func (m *MockVoteService) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*vote.VoteResult, error) {
 	if m.mockError != nil {
 		return nil, m.mockError
 	}
 	return m.mockGetVotesBySession, nil
 }
 
-func (m *MockVoteService) GetVotesByProductID(productID string) ([]*vote.VoteResult, error) {
+func (m *MockVoteService) GetVotesByProductID(ctx context.Context, productID string) ([]*vote.VoteResult, error) {
 	if m.mockError != nil {
 		return nil, m.mockError
 	}
 	return m.mockGetVotesByProduct, nil
 }
 
-func (m *MockVoteService) GetAverageVotesForAllProducts(products map[string]*product.Product) (map[string]*vote.ProductVote, error) {
+func (m *MockVoteService) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*vote.ProductVote, error) {
 	if m.mockError != nil {
 		return nil, m.mockError
 	}
 	return m.mockAvgVotes, nil
 }
+
+func (m *MockVoteService) ListVotes(ctx context.Context, opts vote.ListOptions) (*vote.Page, error) {
+	m.lastListOptions = opts
+	if m.mockError != nil {
+		return nil, m.mockError
+	}
+	page := &vote.Page{Votes: m.mockAllVotes, Next: m.mockNextCursor}
+	if opts.ProductID != "" {
+		page.Votes = m.mockGetVotesByProduct
+	} else if opts.SessionID != "" {
+		page.Votes = m.mockGetVotesBySession
+	}
+	return page, nil
+}
+
+func (m *MockVoteService) EachVote(ctx context.Context, fn func(*vote.VoteResult) error) error {
+	if m.mockError != nil {
+		return m.mockError
+	}
+	for _, v := range m.mockAllVotes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockVoteService) GetVoteHistory(ctx context.Context, productID string) ([]*vote.VoteChange, error) {
+	if m.mockError != nil {
+		return nil, m.mockError
+	}
+	return m.mockHistory, nil
+}
+
+func (m *MockVoteService) GetProductTrend(ctx context.Context, productID string, opts vote.TrendOptions) (*vote.ProductTrend, error) {
+	m.lastTrendOptions = opts
+	if m.mockError != nil {
+		return nil, m.mockError
+	}
+	return m.mockTrend, nil
+}
+
+func (m *MockVoteService) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	return m.mockError
+}
@@ -0,0 +1,140 @@
+// Package health serves the probes of the orchestrator: the liveness of the process and the
+// readiness of the api, which needs its db and a loaded catalog to serve the requests
+package health
+
+import (
+	"api_assignment/api/middleware"
+	"api_assignment/api/models/product"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statuses of the checks and of the probes
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check is a dependency the api needs to serve the requests
+type Check struct {
+	Name string
+	// Run returns why the dependency can't be used, nil when it can
+	Run func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a check
+type CheckResult struct {
+	Status string `json:"status"`
+	// DurationMS is how long the check took, in milliseconds
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all the checks, StatusOK when every check passed
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Run runs the checks concurrently and waits for them at most the timeout.
+// A check still running when the timeout is over fails, whether or not it follows its context
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) Report {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := runCheck(ctx, timeout, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+// runCheck runs the check until it returns or the context is done
+func runCheck(ctx context.Context, timeout time.Duration, check Check) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// CatalogLoaded checks the catalog holds products, an empty catalog means the products could not be loaded
+func CatalogLoaded(catalog *product.Catalog) Check {
+	return Check{Name: "catalog", Run: func(context.Context) error {
+		if catalog.Len() == 0 {
+			return errors.New("the catalog has no products")
+		}
+		return nil
+	}}
+}
+
+// @Summary Liveness probe
+// @Description Tells the process is alive, without checking its dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func Live() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.IndentedJSON(http.StatusOK, gin.H{"status": StatusOK})
+	}
+}
+
+// @Summary Readiness probe
+// @Description Tells whether the api can serve requests: the db answers within the timeout and the catalog holds products.
+// @Description Responds with 503 and the failed checks otherwise.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func Ready(timeout time.Duration, checks ...Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := Run(c.Request.Context(), timeout, checks...)
+		if report.Status != StatusOK {
+			for name, result := range report.Checks {
+				if result.Status != StatusOK {
+					middleware.Logger(c).Warn("readiness check failed", "check", name, "error", result.Error)
+				}
+			}
+			c.IndentedJSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.IndentedJSON(http.StatusOK, report)
+	}
+}
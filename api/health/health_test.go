@@ -0,0 +1,86 @@
+package health
+
+import (
+	"api_assignment/api/models/product"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve calls the probe and decodes its report
+func serve(t *testing.T, probe gin.HandlerFunc) (int, Report) {
+	router := gin.New()
+	router.GET("/probe", probe)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/probe", nil))
+
+	report := Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestLive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	code, report := serve(t, Live())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+}
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	catalog, err := product.NewCatalog(context.Background(), product.NewMemoryStore(&product.Product{ID: "p1", Name: "Product 1"}))
+	require.NoError(t, err)
+	db := Check{Name: "database", Run: func(context.Context) error { return nil }}
+
+	// Test case: every check passes
+	code, report := serve(t, Ready(time.Second, db, CatalogLoaded(catalog)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusOK, report.Checks["catalog"].Status)
+
+	// Test case: the db is down and the catalog is empty
+	down := Check{Name: "database", Run: func(context.Context) error { return errors.New("connection refused") }}
+	empty, err := product.NewCatalog(context.Background(), product.NewMemoryStore())
+	require.NoError(t, err)
+	code, report = serve(t, Ready(time.Second, down, CatalogLoaded(empty)))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, CheckResult{Status: StatusFail, Error: "connection refused"}, withoutDuration(report.Checks["database"]))
+	assert.Equal(t, "the catalog has no products", report.Checks["catalog"].Error)
+}
+
+func TestReadyTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Test case: a check ignoring its context still fails once the timeout is over
+	stuck := make(chan struct{})
+	defer close(stuck)
+	slow := Check{Name: "database", Run: func(context.Context) error {
+		<-stuck
+		return nil
+	}}
+
+	start := time.Now()
+	code, report := serve(t, Ready(50*time.Millisecond, slow))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "timed out after 50ms", report.Checks["database"].Error)
+}
+
+// withoutDuration drops the duration of the result, which changes on every run
+func withoutDuration(result CheckResult) CheckResult {
+	result.DurationMS = 0
+	return result
+}
@@ -0,0 +1,75 @@
+// Package logging creates the structured logger of the api, as text or json lines
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// formats of the logs
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// SessionIDKey is the key of the session ids in the logs, their values are always redacted
+const SessionIDKey = "session_id"
+
+// Config selects the format and the level of the logs
+type Config struct {
+	// Format is Text or JSON
+	Format string
+	Level  slog.Level
+}
+
+// ConfigFromEnv reads the config from the env variables:
+//   - LOG_FORMAT: text (default) or json
+//   - LOG_LEVEL: debug, info (default), warn or error
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Format: strings.ToLower(os.Getenv("LOG_FORMAT"))}
+	if cfg.Format == "" {
+		cfg.Format = Text
+	}
+	if cfg.Format != Text && cfg.Format != JSON {
+		return cfg, fmt.Errorf("LOG_FORMAT must be text or json, not %q", cfg.Format)
+	}
+
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if err := cfg.Level.UnmarshalText([]byte(level)); err != nil {
+			return cfg, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, not %q", level)
+		}
+	}
+	return cfg, nil
+}
+
+// New creates the logger of the config writing to w. Session ids are redacted wherever they are logged
+func New(cfg Config, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+	if cfg.Format == JSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// redact replaces the session ids of the logs with RedactSessionID
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == SessionIDKey && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, RedactSessionID(a.Value.String()))
+	}
+	return a
+}
+
+// RedactSessionID hides a session id behind the beginning of its hash. The logs of a session
+// can still be told apart, but the id, which lists the votes of the session, can't be read from them
+func RedactSessionID(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sessionID))
+	return "redacted:" + hex.EncodeToString(sum[:6])
+}
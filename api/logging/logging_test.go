@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("LOG_LEVEL", "")
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Config{Format: Text, Level: slog.LevelInfo}, cfg)
+
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "debug")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Config{Format: JSON, Level: slog.LevelDebug}, cfg)
+
+	// Test case: invalid values
+	t.Setenv("LOG_FORMAT", "xml")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("LOG_LEVEL", "verbose")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Format: JSON, Level: slog.LevelInfo}, &buf)
+
+	logger.Debug("hidden")
+	logger.Info("vote received", SessionIDKey, "b5b5c578-b561-4fef-9366-ee21e5d21e3a", "product_id", "p1")
+
+	line := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "vote received", line["msg"])
+	assert.Equal(t, "p1", line["product_id"])
+
+	// Test case: the session id is redacted, the same way every time
+	assert.Equal(t, RedactSessionID("b5b5c578-b561-4fef-9366-ee21e5d21e3a"), line[SessionIDKey])
+	assert.NotContains(t, buf.String(), "b5b5c578")
+	assert.NotEqual(t, RedactSessionID("s1"), RedactSessionID("s2"))
+	assert.Equal(t, "", RedactSessionID(""))
+}
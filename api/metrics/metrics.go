@@ -0,0 +1,114 @@
+// Package metrics collects the prometheus metrics of the api: the http requests, the votes,
+// the calls to the vote store and the size of the catalog.
+// The labels only take a bounded set of values, the route templates rather than the paths, and no ids
+package metrics
+
+import (
+	"api_assignment/api/models/product"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of the metrics
+const namespace = "products_vote"
+
+// unmatchedRoute is the route label of the requests matching no route, e.g. scans for random paths
+const unmatchedRoute = "unmatched"
+
+// results of the posted votes
+const (
+	VoteCast    = "cast"
+	VoteUpdated = "updated"
+)
+
+// Metrics holds the collectors of the api in their own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	votes           *prometheus.CounterVec
+	storeDuration   *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+}
+
+// New creates the metrics, along with the ones of the go runtime and of the process
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		votes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_total",
+			Help:      "Posted votes by result, cast for the first time or updated.",
+		}, []string{"result"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "vote_store_duration_seconds",
+			Help:      "Latency of the calls to the vote store by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "vote_store_errors_total",
+			Help:      "Failed calls to the vote store by method.",
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests, m.requestDuration, m.votes, m.storeDuration, m.storeErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	// the results are known ahead, so they show up with 0 before the first vote
+	m.votes.WithLabelValues(VoteCast)
+	m.votes.WithLabelValues(VoteUpdated)
+	return m
+}
+
+// WatchCatalog exposes the number of products in the catalog, read on every scrape
+func (m *Metrics) WatchCatalog(catalog *product.Catalog) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "catalog_products",
+		Help:      "Products in the catalog.",
+	}, func() float64 { return float64(catalog.Len()) }))
+}
+
+// Middleware counts and times the requests by the template of their route, e.g. /votes/product/:id
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/votes/product/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/votes/product/1", "/votes/product/2", "/wp-login.php"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Test case: the requests are labelled by route template, not by path
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/votes/product/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestInstrumentVotes(t *testing.T) {
+	m := New()
+	store := m.InstrumentVotes(vote.NewMemoryStore())
+
+	// Test case: the votes are counted as cast, then as updated
+	_, err := store.PostVote(context.Background(), &vote.VoteResult{ProductID: "p1", SessionID: "s1", Rate: 5})
+	require.NoError(t, err)
+	_, err = store.PostVote(context.Background(), &vote.VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	require.NoError(t, err)
+	_, err = store.PostVote(context.Background(), &vote.VoteResult{ProductID: "p2", SessionID: "s1", Rate: 6})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.votes.WithLabelValues(VoteCast)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.votes.WithLabelValues(VoteUpdated)))
+
+	// Test case: the expected errors are not failures
+	assert.ErrorIs(t, store.DeleteVote(context.Background(), "p3", "s1"), vote.ErrNotFound)
+	_, err = store.ListVotes(context.Background(), vote.ListOptions{Cursor: "invalid"})
+	assert.ErrorIs(t, err, vote.ErrInvalidCursor)
+	stop := errors.New("client went away")
+	assert.ErrorIs(t, store.EachVote(context.Background(), func(*vote.VoteResult) error { return stop }), stop)
+	assert.Equal(t, 0, testutil.CollectAndCount(m.storeErrors))
+
+	// every call is timed by method
+	assert.Equal(t, 4, testutil.CollectAndCount(m.storeDuration))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	catalog, err := product.NewCatalog(context.Background(), product.NewMemoryStore(&product.Product{ID: "p1", Name: "Product 1"}))
+	require.NoError(t, err)
+	m.WatchCatalog(catalog)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "products_vote_catalog_products 1")
+	assert.Contains(t, w.Body.String(), `products_vote_votes_total{result="cast"} 0`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
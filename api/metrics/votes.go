@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"context"
+	"errors"
+	"time"
+)
+
+// instrumentedVotes times the calls to a vote store and counts their errors and the posted votes
+type instrumentedVotes struct {
+	store   vote.Store
+	metrics *Metrics
+}
+
+// InstrumentVotes wraps the store so its calls are measured
+func (m *Metrics) InstrumentVotes(store vote.Store) vote.Store {
+	return &instrumentedVotes{store: store, metrics: m}
+}
+
+// observe records the call of the method that started at start. The errors the handlers expect,
+// like an unknown vote or a client going away, are not failures of the store
+func (s *instrumentedVotes) observe(method string, start time.Time, err error) {
+	s.metrics.storeDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, vote.ErrNotFound) && !errors.Is(err, vote.ErrInvalidCursor) && !errors.Is(err, context.Canceled) {
+		s.metrics.storeErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (s *instrumentedVotes) AllVotes(ctx context.Context) ([]*vote.VoteResult, error) {
+	start := time.Now()
+	votes, err := s.store.AllVotes(ctx)
+	s.observe("AllVotes", start, err)
+	return votes, err
+}
+
+func (s *instrumentedVotes) PostVote(ctx context.Context, newVote *vote.VoteResult) (*bool, error) {
+	start := time.Now()
+	alreadyExist, err := s.store.PostVote(ctx, newVote)
+	s.observe("PostVote", start, err)
+	if err == nil && alreadyExist != nil {
+		result := VoteCast
+		if *alreadyExist {
+			result = VoteUpdated
+		}
+		s.metrics.votes.WithLabelValues(result).Inc()
+	}
+	return alreadyExist, err
+}
+
+func (s *instrumentedVotes) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*vote.VoteResult, error) {
+	start := time.Now()
+	votes, err := s.store.GetVotesBySessionID(ctx, sessionID)
+	s.observe("GetVotesBySessionID", start, err)
+	return votes, err
+}
+
+func (s *instrumentedVotes) GetVotesByProductID(ctx context.Context, productID string) ([]*vote.VoteResult, error) {
+	start := time.Now()
+	votes, err := s.store.GetVotesByProductID(ctx, productID)
+	s.observe("GetVotesByProductID", start, err)
+	return votes, err
+}
+
+func (s *instrumentedVotes) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*vote.ProductVote, error) {
+	start := time.Now()
+	avgs, err := s.store.GetAverageVotesForAllProducts(ctx, products)
+	s.observe("GetAverageVotesForAllProducts", start, err)
+	return avgs, err
+}
+
+func (s *instrumentedVotes) ListVotes(ctx context.Context, opts vote.ListOptions) (*vote.Page, error) {
+	start := time.Now()
+	page, err := s.store.ListVotes(ctx, opts)
+	s.observe("ListVotes", start, err)
+	return page, err
+}
+
+// EachVote is timed until the last vote is handled by fn, e.g. written to the client.
+// The errors of fn are not failures of the store
+func (s *instrumentedVotes) EachVote(ctx context.Context, fn func(*vote.VoteResult) error) error {
+	start := time.Now()
+	var fnErr error
+	err := s.store.EachVote(ctx, func(v *vote.VoteResult) error {
+		fnErr = fn(v)
+		return fnErr
+	})
+	if fnErr != nil && errors.Is(err, fnErr) {
+		s.observe("EachVote", start, nil)
+	} else {
+		s.observe("EachVote", start, err)
+	}
+	return err
+}
+
+func (s *instrumentedVotes) GetVoteHistory(ctx context.Context, productID string) ([]*vote.VoteChange, error) {
+	start := time.Now()
+	changes, err := s.store.GetVoteHistory(ctx, productID)
+	s.observe("GetVoteHistory", start, err)
+	return changes, err
+}
+
+func (s *instrumentedVotes) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	start := time.Now()
+	err := s.store.DeleteVote(ctx, productID, sessionID)
+	s.observe("DeleteVote", start, err)
+	return err
+}
+
+func (s *instrumentedVotes) GetProductTrend(ctx context.Context, productID string, opts vote.TrendOptions) (*vote.ProductTrend, error) {
+	start := time.Now()
+	trend, err := s.store.GetProductTrend(ctx, productID, opts)
+	s.observe("GetProductTrend", start, err)
+	return trend, err
+}
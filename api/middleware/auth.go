@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"api_assignment/api/models/apikey"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyKey is the key of the api key of the request in the context
+const APIKeyKey = "api_key"
+
+// adminTokenKey is the key the ADMIN_TOKEN authenticates as
+var adminTokenKey = &apikey.Key{ID: "admin-token", Name: "ADMIN_TOKEN", Role: apikey.RoleAdmin}
+
+// Authenticate is a middleware function identifying the clients by the api key they send in the
+// Authorization header, as `Bearer <token>`. Requests without a token go on anonymously, the ones with
+// an unknown or revoked token are rejected with 401.
+// The admin token, when not empty, is accepted as a key with the admin role
+func Authenticate(keys apikey.Store, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found {
+			c.Next()
+			return
+		}
+
+		if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			c.Set(APIKeyKey, adminTokenKey)
+			c.Next()
+			return
+		}
+
+		key, err := keys.FindKey(c.Request.Context(), apikey.Hash(token))
+		if errors.Is(err, apikey.ErrNotFound) || (err == nil && key.Revoked()) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong, please try again later."})
+			Logger(c).Error("finding the api key failed", "error", err)
+			return
+		}
+
+		c.Set(APIKeyKey, key)
+		c.Next()
+	}
+}
+
+// APIKey returns the key the request was authenticated with, nil for anonymous requests
+func APIKey(c *gin.Context) *apikey.Key {
+	key, _ := c.Value(APIKeyKey).(*apikey.Key)
+	return key
+}
+
+// RequireRole is a middleware function to only let through the requests authenticated with a key
+// allowing the role. It must run after Authenticate.
+// Anonymous requests are rejected with 401, keys with a lesser role with 403
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKey(c)
+		if key == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+		if !apikey.Allows(key.Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"api_assignment/api/models/apikey"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKey saves a key with the role to the store and returns its token
+func newTestKey(t *testing.T, keys apikey.Store, role string) string {
+	key, token, err := apikey.New("test", role)
+	require.NoError(t, err)
+	require.NoError(t, keys.CreateKey(context.Background(), key))
+	return token
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := apikey.NewMemoryStore()
+	voter := newTestKey(t, keys, apikey.RoleVoter)
+	analyst := newTestKey(t, keys, apikey.RoleAnalyst)
+	admin := newTestKey(t, keys, apikey.RoleAdmin)
+	revoked := newTestKey(t, keys, apikey.RoleAdmin)
+	key, err := keys.FindKey(context.Background(), apikey.Hash(revoked))
+	require.NoError(t, err)
+	require.NoError(t, keys.RevokeKey(context.Background(), key.ID, time.Now()))
+
+	router := gin.New()
+	router.Use(Authenticate(keys, "admin-token"))
+	router.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/analytics", RequireRole(apikey.RoleAnalyst), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Test case: anonymous requests only reach the public routes
+	assert.Equal(t, http.StatusOK, get(router, "/public", "10.0.0.1", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/analytics", "10.0.0.1", "").Code)
+
+	// Test case: the roles include the lesser ones
+	assert.Equal(t, http.StatusForbidden, get(router, "/analytics", "10.0.0.1", voter).Code)
+	assert.Equal(t, http.StatusOK, get(router, "/analytics", "10.0.0.1", analyst).Code)
+	assert.Equal(t, http.StatusOK, get(router, "/analytics", "10.0.0.1", admin).Code)
+	assert.Equal(t, http.StatusOK, get(router, "/analytics", "10.0.0.1", "admin-token").Code)
+
+	// Test case: unknown and revoked keys are rejected, even on the public routes
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/public", "10.0.0.1", "pv_unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/analytics", "10.0.0.1", revoked).Code)
+}
+
+func TestAuthenticateWithoutAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Authenticate(apikey.NewMemoryStore(), ""))
+	router.GET("/admin", RequireRole(apikey.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Test case: an empty admin token never matches
+	req := get(router, "/admin", "10.0.0.1", "")
+	assert.Equal(t, http.StatusUnauthorized, req.Code)
+	req = get(router, "/admin", "10.0.0.1", " ")
+	assert.Equal(t, http.StatusUnauthorized, req.Code)
+}
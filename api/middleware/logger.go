@@ -1,43 +1,84 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
+	"regexp"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// CheckSession is a middleware function to create logs for each request
-func Log() gin.HandlerFunc {
+// RequestIDHeader carries the id of the request, from the client or the proxy and back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// keys of the request id, the trace id and of the logger of the request in the context
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	loggerKey    = "logger"
+)
+
+// validRequestID keeps the ids sent by the clients from forging log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Log is a middleware function giving each request an id and a logger, and logging each request once done.
+// The id is the X-Request-ID of the request when it has a valid one, a new uuid otherwise, and is sent back
+// in the X-Request-ID header. Every line logged through Logger carries it, along with the id of the trace
+// of the request when it is traced, see otelgin
+func Log(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
 
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		reqLogger := logger.With(RequestIDKey, requestID)
+		if spanCtx := trace.SpanContextFromContext(c.Request.Context()); spanCtx.HasTraceID() {
+			c.Set(TraceIDKey, spanCtx.TraceID().String())
+			reqLogger = reqLogger.With(TraceIDKey, spanCtx.TraceID().String())
+		}
+		c.Set(loggerKey, reqLogger)
+
 		// Process request
 		c.Next()
 
-		// Stop timer
-		end := time.Now()
-		latency := end.Sub(start)
-
-		// Additional fields
-		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
-		method := c.Request.Method
-		userAgent := c.Request.UserAgent()
-		responseSize := c.Writer.Size()
-
-		// Log the standard fields (like the default logger)
-		fmt.Printf("[GIN] %v | %3d | %v | %s | %-7s %s | %d bytes | User-Agent: %s\n",
-			end.Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			path,
-			responseSize,
-			userAgent,
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		reqLogger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+			slog.String("user_agent", c.Request.UserAgent()),
 		)
 	}
 }
+
+// Logger returns the logger of the request, slog.Default() when Log did not run
+func Logger(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the id of the request, empty when Log did not run
+func RequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// TraceID returns the id of the trace of the request, empty when the request is not traced or Log did not run
+func TraceID(c *gin.Context) string {
+	return c.GetString(TraceIDKey)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"api_assignment/api/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logLines decodes the json lines of the logs
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		decoded := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded))
+		lines = append(lines, decoded)
+	}
+	buf.Reset()
+	return lines
+}
+
+func TestLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	logger := logging.New(logging.Config{Format: logging.JSON, Level: slog.LevelDebug}, &buf)
+
+	router := gin.New()
+	router.Use(Log(logger))
+	router.Use(sessions.Sessions("session_cookie", cookie.NewStore([]byte("secret"))))
+	router.Use(CheckSession())
+	router.GET("/products", func(c *gin.Context) {
+		Logger(c).Info("listing the products")
+		c.Status(http.StatusOK)
+	})
+
+	// Test case: the request id of the client is kept and sent back
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+
+	// every line carries the request id, the new session is logged with a redacted id
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.Equal(t, "abc-123", line[RequestIDKey])
+	}
+	assert.Equal(t, "new session created", lines[0]["msg"])
+	assert.True(t, strings.HasPrefix(lines[0][logging.SessionIDKey].(string), "redacted:"))
+	assert.Equal(t, "listing the products", lines[1]["msg"])
+	assert.Equal(t, "request", lines[2]["msg"])
+	assert.Equal(t, "GET", lines[2]["method"])
+	assert.Equal(t, "/products", lines[2]["path"])
+	assert.Equal(t, float64(http.StatusOK), lines[2]["status"])
+
+	// Test case: invalid request ids are replaced
+	req = httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(RequestIDHeader, "forged\nline")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	requestID := w.Header().Get(RequestIDHeader)
+	assert.Len(t, requestID, 36)
+	for _, line := range logLines(t, &buf) {
+		assert.Equal(t, requestID, line[RequestIDKey])
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"api_assignment/api/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc returns the key the request is limited by
+type KeyFunc func(c *gin.Context) string
+
+// ByIP limits the requests by client ip
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// BySession limits the requests by session id, it must run after CheckSession.
+// Requests without a session are limited by ip
+func BySession(c *gin.Context) string {
+	if sessionID, ok := sessions.Default(c).Get("session_id").(string); ok {
+		return "session:" + sessionID
+	}
+	return ByIP(c)
+}
+
+// ByAPIKey limits the requests by the api key they were authenticated with, it must run after Authenticate.
+// Anonymous requests are limited by ip
+func ByAPIKey(c *gin.Context) string {
+	if key := APIKey(c); key != nil {
+		return "key:" + key.ID
+	}
+	return ByIP(c)
+}
+
+// RateLimit is a middleware function limiting the requests of each key, it can be used on the whole
+// router as well as on a group or a route. The state of the limit is sent in the RateLimit-* headers,
+// requests over the limit are rejected with 429 and a Retry-After header.
+// When the limiter fails, e.g. the db is down, the requests are let through. A nil limiter is no limit
+func RateLimit(limiter ratelimit.Limiter, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter != nil && !allow(c, limiter, key(c), "Too many requests, please try again later.") {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// allow takes a token of the key and sets the RateLimit-* headers, it aborts the request
+// with the message when there is no token left
+func allow(c *gin.Context, limiter ratelimit.Limiter, key, message string) bool {
+	result, err := limiter.Allow(c.Request.Context(), key)
+	if err != nil {
+		Logger(c).Error("rate limit failed, the request is let through", "error", err)
+		return true
+	}
+
+	setRateLimitHeaders(c, limiter.Limit(), result)
+	if !result.Allowed {
+		c.Header("Retry-After", seconds(result.RetryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": message})
+	}
+	return result.Allowed
+}
+
+// setRateLimitHeaders sets the headers of the IETF draft on rate limit headers. When several limits
+// apply to the request, the headers tell about the one with the fewest requests left
+func setRateLimitHeaders(c *gin.Context, limit ratelimit.Limit, result ratelimit.Result) {
+	header := c.Writer.Header()
+	if remaining := header.Get("RateLimit-Remaining"); remaining != "" {
+		if current, err := strconv.Atoi(remaining); err == nil && current <= result.Remaining {
+			return
+		}
+	}
+
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.Count
+	}
+	header.Set("RateLimit-Limit", strconv.Itoa(burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", seconds(result.Reset))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Count, seconds(limit.Period)))
+}
+
+// seconds formats the duration as the seconds of the headers, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
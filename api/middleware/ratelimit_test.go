@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"api_assignment/api/models/apikey"
+	"api_assignment/api/ratelimit"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingLimiter is a limiter whose backend is down
+type failingLimiter struct{}
+
+func (failingLimiter) Limit() ratelimit.Limit {
+	return ratelimit.Limit{Count: 1, Period: time.Minute}
+}
+
+func (failingLimiter) Allow(context.Context, string) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("db is down")
+}
+
+func get(router *gin.Engine, path, ip, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := apikey.NewMemoryStore()
+	token1, token2 := newTestKey(t, keys, apikey.RoleAdmin), newTestKey(t, keys, apikey.RoleAdmin)
+
+	router := gin.New()
+	router.Use(RateLimit(ratelimit.NewBuckets(ratelimit.Limit{Count: 3, Period: time.Minute}), ByIP))
+	router.Use(Authenticate(keys, ""))
+	router.GET("/a", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/b", RateLimit(ratelimit.NewBuckets(ratelimit.Limit{Count: 1, Period: time.Minute}), ByAPIKey), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Test case: the headers tell about the limit
+	w := get(router, "/a", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "20", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "3;w=60", w.Header().Get("RateLimit-Policy"))
+
+	// Test case: the route limit is stricter than the global one, the headers tell about it
+	w = get(router, "/b", "10.0.0.1", token1)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	// Test case: the route limit is by api key
+	w = get(router, "/b", "10.0.0.1", token1)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Too many requests")
+
+	// Test case: the global limit is by ip, whatever the key
+	w = get(router, "/b", "10.0.0.1", token2)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, get(router, "/b", "10.0.0.2", token2).Code)
+}
+
+func TestRateLimitPassThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/down", RateLimit(failingLimiter{}, ByIP), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/off", RateLimit(nil, ByIP), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Test case: the requests go through when the limiter fails
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get(router, "/down", "10.0.0.1", "").Code)
+	}
+
+	// Test case: a nil limiter is no limit
+	w := get(router, "/off", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	router.Use(RateLimit(ratelimit.NewBuckets(ratelimit.Limit{Count: 1, Period: time.Minute}), ByIP))
+	router.GET("/a", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/a", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, request(http.MethodGet).Code)
+
+	// Test case: a limited cross-origin request can be read by the browser, along with its Retry-After
+	w := request(http.MethodGet)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Retry-After")
+
+	// Test case: the preflights are not limited
+	assert.Equal(t, http.StatusNoContent, request(http.MethodOptions).Code)
+}
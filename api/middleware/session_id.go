@@ -1,14 +1,17 @@
 package middleware
 
 import (
-	"fmt"
+	"api_assignment/api/logging"
+	"net/http"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// CheckSession is a middleware function to check and create a session if it doesn't exist
+// CheckSession is a middleware function to check and create a session if it doesn't exist.
+// The request is rejected with 503 when the new session can't be saved, e.g. when the session store
+// failed to load the existing session: the votes would otherwise go to a session nobody keeps
 func CheckSession() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
@@ -22,11 +25,13 @@ func CheckSession() gin.HandlerFunc {
 			session.Set("session_id", newSessionID)
 
 			// Save the session
-			session.Save()
+			if err := session.Save(); err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": "Something went wrong, please try again later."})
+				Logger(c).Error("saving the new session failed", "error", err)
+				return
+			}
 
-			fmt.Println("New session created with ID:", newSessionID)
-		} else {
-			fmt.Println("Existing session found with ID:", sessionID)
+			Logger(c).Debug("new session created", logging.SessionIDKey, newSessionID)
 		}
 
 		// Continue
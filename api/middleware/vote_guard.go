@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"api_assignment/api/ratelimit"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SuspiciousVoteKey is set on the context by LimitVotes when the vote of the request has to be flagged
+const SuspiciousVoteKey = "suspicious_vote"
+
+const tooManyVotes = "Too many votes, please try again later."
+
+// suspiciousSessionKey flags, in the session itself, the sessions created over the cap of their ip
+const suspiciousSessionKey = "suspicious"
+
+// VoteGuardConfig holds the limits of the vote guard, a nil limit is no limit
+type VoteGuardConfig struct {
+	// PerIP and PerSession limit how often an ip and a session can vote
+	PerIP      *ratelimit.Limit
+	PerSession *ratelimit.Limit
+	// NewSessionsPerIP caps the sessions an ip can create. The sessions created over the cap
+	// still work, but their votes are flagged as suspicious
+	NewSessionsPerIP *ratelimit.Limit
+	// Backend keeps the counters, in memory when nil
+	Backend ratelimit.Backend
+}
+
+// VoteGuard protects the votes from scripts casting votes in bulk, e.g. by dropping their cookie
+// to get a new session for every vote
+type VoteGuard struct {
+	perIP       ratelimit.Limiter
+	perSession  ratelimit.Limiter
+	newSessions ratelimit.Limiter
+}
+
+// NewVoteGuard creates the guard of the config
+func NewVoteGuard(cfg VoteGuardConfig) *VoteGuard {
+	backend := cfg.Backend
+	if backend == nil {
+		backend = ratelimit.Memory{}
+	}
+	newLimiter := func(name string, limit *ratelimit.Limit) ratelimit.Limiter {
+		if limit == nil {
+			return nil
+		}
+		return backend.NewLimiter(name, *limit)
+	}
+	return &VoteGuard{
+		perIP:       newLimiter("votes_ip", cfg.PerIP),
+		perSession:  newLimiter("votes_session", cfg.PerSession),
+		newSessions: newLimiter("new_sessions", cfg.NewSessionsPerIP),
+	}
+}
+
+// TrackNewSessions counts the sessions created by each ip, and flags the ones over the cap.
+// It has to run on every route, right before CheckSession, so the flag is saved along with the new session
+func (g *VoteGuard) TrackNewSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		if session.Get("session_id") == nil {
+			if g.newSessions != nil {
+				result, err := g.newSessions.Allow(c.Request.Context(), c.ClientIP())
+				if err != nil {
+					Logger(c).Error("rate limit of the new sessions failed", "error", err)
+				} else if !result.Allowed {
+					session.Set(suspiciousSessionKey, true)
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// LimitVotes rejects the votes over the limits of the ip or the session with 429, and tells the
+// vote handler to flag the votes of suspicious sessions through SuspiciousVoteKey.
+// Only the votes of sessions created over the cap of their ip are suspicious: first-time voters,
+// clients without a cookie jar and cross-origin clients vote with a session created by the vote itself
+func (g *VoteGuard) LimitVotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		sessionID, _ := session.Get("session_id").(string)
+
+		if g.perIP != nil && !allow(c, g.perIP, c.ClientIP(), tooManyVotes) {
+			return
+		}
+		if g.perSession != nil && !allow(c, g.perSession, sessionID, tooManyVotes) {
+			return
+		}
+
+		suspicious := session.Get(suspiciousSessionKey) == true
+		c.Set(SuspiciousVoteKey, suspicious)
+
+		c.Next()
+	}
+}
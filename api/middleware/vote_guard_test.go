@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"api_assignment/api/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupGuardRouter(cfg VoteGuardConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	guard := NewVoteGuard(cfg)
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session_cookie", cookie.NewStore([]byte("secret"))))
+	router.Use(guard.TrackNewSessions())
+	router.Use(CheckSession())
+	router.GET("/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/votes", guard.LimitVotes(), func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"suspicious": c.GetBool(SuspiciousVoteKey)})
+	})
+	return router
+}
+
+// newSession gets a session cookie the way a browser does, by loading the products
+func newSession(t *testing.T, router *gin.Engine, ip string) *http.Cookie {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func vote(router *gin.Engine, ip string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/votes", nil)
+	req.RemoteAddr = ip + ":1234"
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLimitVotes(t *testing.T) {
+	router := setupGuardRouter(VoteGuardConfig{
+		PerIP:      &ratelimit.Limit{Count: 3, Period: time.Hour},
+		PerSession: &ratelimit.Limit{Count: 2, Period: time.Hour},
+	})
+	cookie := newSession(t, router, "10.0.0.1")
+
+	// Test case: the session is limited first
+	assert.Equal(t, http.StatusCreated, vote(router, "10.0.0.1", cookie).Code)
+	assert.Equal(t, http.StatusCreated, vote(router, "10.0.0.1", cookie).Code)
+	w := vote(router, "10.0.0.1", cookie)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+
+	// Test case: then the ip, whatever the session
+	other := newSession(t, router, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, vote(router, "10.0.0.1", other).Code)
+	assert.Equal(t, http.StatusCreated, vote(router, "10.0.0.2", other).Code)
+}
+
+func TestFlagSuspiciousVotes(t *testing.T) {
+	router := setupGuardRouter(VoteGuardConfig{NewSessionsPerIP: &ratelimit.Limit{Count: 2, Period: time.Hour}})
+
+	// Test case: votes of sessions within the cap are counted
+	cookie := newSession(t, router, "10.0.0.1")
+	w := vote(router, "10.0.0.1", cookie)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"suspicious": false}`, w.Body.String())
+
+	// Test case: votes without a session are counted, the session created by the vote is within the cap
+	w = vote(router, "10.0.0.1", nil)
+	assert.JSONEq(t, `{"suspicious": false}`, w.Body.String())
+
+	// Test case: the cap is reached, the sessions created over it are flagged for good
+	cookie = newSession(t, router, "10.0.0.1")
+	for i := 0; i < 2; i++ {
+		w = vote(router, "10.0.0.1", cookie)
+		assert.JSONEq(t, `{"suspicious": true}`, w.Body.String())
+	}
+
+	// Test case: other ips have their own cap
+	cookie = newSession(t, router, "10.0.0.2")
+	w = vote(router, "10.0.0.2", cookie)
+	assert.JSONEq(t, `{"suspicious": false}`, w.Body.String())
+}
@@ -0,0 +1,107 @@
+// Package apikey holds the api keys the clients authenticate with, and the roles they grant
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// roles of the keys, each role can do everything the roles before it can
+const (
+	// RoleVoter can vote, e.g. a kiosk
+	RoleVoter = "voter"
+	// RoleAnalyst can also read the votes and the analytics
+	RoleAnalyst = "analyst"
+	// RoleAdmin can also manage the products
+	RoleAdmin = "admin"
+)
+
+// roleLevels orders the roles
+var roleLevels = map[string]int{RoleVoter: 1, RoleAnalyst: 2, RoleAdmin: 3}
+
+// ValidRole tells whether the role exists
+func ValidRole(role string) bool {
+	return roleLevels[role] > 0
+}
+
+// Allows tells whether the role can do what the required role can
+func Allows(role, required string) bool {
+	return ValidRole(role) && roleLevels[role] >= roleLevels[required]
+}
+
+// errors returned by the stores
+var (
+	ErrNotFound = errors.New("api key not found")
+)
+
+// tokenPrefix starts the tokens, so they are easy to recognize, e.g. by secret scanners
+const tokenPrefix = "pv_"
+
+// Key is an api key. Only the hash of its token is saved, the token itself is shown once, when the key is created
+type Key struct {
+	ID        string     `json:"id" bson:"id"`
+	Name      string     `json:"name" bson:"name"`
+	Role      string     `json:"role" bson:"role"`
+	Hash      string     `json:"-" bson:"hash"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// Revoked tells whether the key was revoked
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// New creates a key with the role, and returns it along with its token
+func New(name, role string) (*Key, string, error) {
+	if !ValidRole(role) {
+		return nil, "", fmt.Errorf("invalid role %q, expected voter, analyst or admin", role)
+	}
+	if name == "" {
+		return nil, "", errors.New("the name of the key can't be empty")
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key := &Key{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Role:      role,
+		Hash:      Hash(token),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	return key, token, nil
+}
+
+// Hash returns the hash of the token the keys are looked up by. The tokens are 256 random bits,
+// which can't be brute forced, so a fast hash is enough and no salt is needed
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store is the contract every api key storage backend has to fulfil
+type Store interface {
+	CreateKey(ctx context.Context, key *Key) error
+	// FindKey returns the key with the hash, revoked or not, ErrNotFound if there is none
+	FindKey(ctx context.Context, hash string) (*Key, error)
+	// ListKeys returns every key, oldest first
+	ListKeys(ctx context.Context) ([]*Key, error)
+	// RevokeKey revokes the key with the id, ErrNotFound if there is none.
+	// Revoking a revoked key keeps the time of its first revocation
+	RevokeKey(ctx context.Context, id string, at time.Time) error
+}
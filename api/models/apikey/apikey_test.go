@@ -0,0 +1,95 @@
+package apikey
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	assert.True(t, Allows(RoleAdmin, RoleAnalyst))
+	assert.True(t, Allows(RoleAnalyst, RoleAnalyst))
+	assert.True(t, Allows(RoleAnalyst, RoleVoter))
+	assert.False(t, Allows(RoleVoter, RoleAnalyst))
+	assert.False(t, Allows(RoleAnalyst, RoleAdmin))
+	assert.False(t, Allows("", RoleVoter))
+}
+
+func TestNew(t *testing.T) {
+	key, token, err := New("dashboard", RoleAnalyst)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+	assert.Equal(t, Hash(token), key.Hash)
+	assert.NotContains(t, key.Hash, token)
+	assert.Len(t, key.ID, 16)
+	assert.False(t, key.Revoked())
+
+	// Test case: the tokens are random
+	other, otherToken, err := New("dashboard", RoleAnalyst)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, otherToken)
+	assert.NotEqual(t, key.ID, other.ID)
+
+	// Test case: invalid keys
+	_, _, err = New("dashboard", "root")
+	assert.Error(t, err)
+	_, _, err = New("", RoleVoter)
+	assert.Error(t, err)
+}
+
+// testStore runs the same checks against every Store implementation
+func testStore(t *testing.T, store Store) {
+	first, token, err := New("first", RoleAdmin)
+	require.NoError(t, err)
+	second, _, err := New("second", RoleVoter)
+	require.NoError(t, err)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	require.NoError(t, store.CreateKey(context.Background(), first))
+	require.NoError(t, store.CreateKey(context.Background(), second))
+
+	key, err := store.FindKey(context.Background(), Hash(token))
+	require.NoError(t, err)
+	assert.Equal(t, first, key)
+
+	_, err = store.FindKey(context.Background(), Hash("unknown"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Test case: revoked keys are still found, with their revocation time
+	revokedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.RevokeKey(context.Background(), first.ID, revokedAt))
+	require.NoError(t, store.RevokeKey(context.Background(), first.ID, revokedAt.Add(time.Hour)))
+	key, err = store.FindKey(context.Background(), Hash(token))
+	require.NoError(t, err)
+	require.True(t, key.Revoked())
+	assert.Equal(t, revokedAt, *key.RevokedAt)
+
+	assert.ErrorIs(t, store.RevokeKey(context.Background(), "unknown", revokedAt), ErrNotFound)
+
+	keys, err := store.ListKeys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "first", keys[0].Name)
+	assert.Equal(t, second, keys[1])
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestSQLStore(t *testing.T) {
+	db, err := database.Open(database.SQLite, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	testStore(t, SQLStore{DB: db})
+
+	// Test case: the lookup stops with the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SQLStore{DB: db}.FindKey(ctx, Hash("unknown"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,72 @@
+package apikey
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory implementation of Store, safe for concurrent use.
+// It is meant for running the API locally or in integration tests without a database
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+// NewMemoryStore creates an empty in-memory api key store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// CreateKey saves a copy of the key
+func (m *MemoryStore) CreateKey(ctx context.Context, key *Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *key
+	m.keys = append(m.keys, &cp)
+	return nil
+}
+
+// FindKey returns a copy of the key with the hash
+func (m *MemoryStore) FindKey(ctx context.Context, hash string) (*Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			cp := *key
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListKeys returns a copy of the keys, in the order they were created
+func (m *MemoryStore) ListKeys(ctx context.Context) ([]*Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(m.keys))
+	for _, key := range m.keys {
+		cp := *key
+		keys = append(keys, &cp)
+	}
+	return keys, nil
+}
+
+// RevokeKey sets the revocation time of the key with the id
+func (m *MemoryStore) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.keys {
+		if key.ID == id {
+			if key.RevokedAt == nil {
+				key.RevokedAt = &at
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,108 @@
+package apikey
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KeyModel is the MongoDB implementation of Store
+type KeyModel struct {
+	DB *mongo.Client
+	// DBName is the name of the database holding the collection, database.DefaultMongoDB when empty
+	DBName string
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+func (kModel KeyModel) collection() *mongo.Collection {
+	return database.MongoDatabase(kModel.DB, kModel.DBName).Collection("api_keys")
+}
+
+// EnsureIndexes creates the unique indexes the keys are looked up by
+func (kModel KeyModel) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx, kModel.Timeout)
+	defer cancel()
+
+	_, err := kModel.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	return err
+}
+
+// CreateKey inserts the key
+func (kModel KeyModel) CreateKey(ctx context.Context, key *Key) error {
+	ctx, cancel := database.WithTimeout(ctx, kModel.Timeout)
+	defer cancel()
+
+	_, err := kModel.collection().InsertOne(ctx, key)
+	return err
+}
+
+// FindKey returns the key with the hash
+func (kModel KeyModel) FindKey(ctx context.Context, hash string) (*Key, error) {
+	ctx, cancel := database.WithTimeout(ctx, kModel.Timeout)
+	defer cancel()
+
+	key := &Key{}
+	err := kModel.collection().FindOne(ctx, bson.D{{Key: "hash", Value: hash}}).Decode(key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListKeys returns the keys, oldest first
+func (kModel KeyModel) ListKeys(ctx context.Context) ([]*Key, error) {
+	ctx, cancel := database.WithTimeout(ctx, kModel.Timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}})
+	cur, err := kModel.collection().Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*Key{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey sets the revocation time of the key with the id, unless it is already revoked
+func (kModel KeyModel) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	ctx, cancel := database.WithTimeout(ctx, kModel.Timeout)
+	defer cancel()
+
+	coll := kModel.collection()
+
+	result, err := coll.UpdateOne(ctx,
+		bson.D{{Key: "id", Value: id}, {Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: at}}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	// either the key is already revoked or it doesn't exist
+	count, err := coll.CountDocuments(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
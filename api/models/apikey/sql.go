@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SQLStore is the database/sql implementation of Store, it works with both sqlite and postgres
+type SQLStore struct {
+	DB *database.DB
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+const keyColumns = "id, name, role, hash, created_at, revoked_at"
+
+// CreateKey inserts the key
+func (s SQLStore) CreateKey(ctx context.Context, key *Key) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, s.DB.Rebind(`INSERT INTO api_keys (`+keyColumns+`) VALUES (?, ?, ?, ?, ?, ?)`),
+		key.ID, key.Name, key.Role, key.Hash, key.CreatedAt, key.RevokedAt)
+	return err
+}
+
+// FindKey returns the key with the hash
+func (s SQLStore) FindKey(ctx context.Context, hash string) (*Key, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	key, err := scanKey(s.DB.QueryRowContext(ctx, s.DB.Rebind(`SELECT `+keyColumns+` FROM api_keys WHERE hash = ?`), hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// ListKeys returns the keys, oldest first
+func (s SQLStore) ListKeys(ctx context.Context) ([]*Key, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+keyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey sets the revocation time of the key with the id, unless it is already revoked
+func (s SQLStore) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, s.DB.Rebind(`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`), at, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanKey reads a key selected with keyColumns
+func scanKey(row interface{ Scan(...any) error }) (*Key, error) {
+	key := &Key{}
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &key.Role, &key.Hash, &key.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	if revokedAt.Valid {
+		t := revokedAt.Time.UTC()
+		key.RevokedAt = &t
+	}
+	return key, nil
+}
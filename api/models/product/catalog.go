@@ -0,0 +1,179 @@
+package product
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Catalog is the in-memory copy of the products used by the handlers, written through to a Store.
+// Readers get an immutable snapshot of the products without locking; writers are serialized,
+// apply the change to the store first and then swap in an updated copy of the snapshot,
+// so the catalog and the store see the changes in the same order
+type Catalog struct {
+	store Store
+
+	// mu serializes the writers
+	mu       sync.Mutex
+	products atomic.Pointer[map[string]*Product]
+}
+
+// NewCatalog creates a catalog over the store, loaded with the products of the store
+func NewCatalog(ctx context.Context, store Store) (*Catalog, error) {
+	products, err := store.FetchProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &Catalog{store: store}
+	catalog.products.Store(&products)
+	return catalog, nil
+}
+
+// Reload replaces the products of the catalog with the ones of the store,
+// to pick up the products changed directly in the db
+func (c *Catalog) Reload(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	products, err := c.store.FetchProducts(ctx)
+	if err != nil {
+		return err
+	}
+	c.products.Store(&products)
+	return nil
+}
+
+// RefreshEvery reloads the catalog on every interval until the context is cancelled.
+// Failed reloads are reported and the current products are kept
+func (c *Catalog) RefreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Reload(ctx); err != nil {
+				slog.Error("reloading the products failed", "error", err)
+			}
+		}
+	}
+}
+
+// ReloadOnChange reloads the catalog whenever the watcher reports a change of the products,
+// until the context is cancelled. A broken watch is restarted after a growing delay
+func (c *Catalog) ReloadOnChange(ctx context.Context, watcher Watcher) {
+	const maxDelay = time.Minute
+	delay := time.Second
+
+	for {
+		err := watcher.WatchProducts(ctx, func() {
+			// the watch works again
+			delay = time.Second
+			if err := c.Reload(ctx); err != nil {
+				slog.Error("reloading the products failed", "error", err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("watching the products failed, retrying", "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// Products returns a snapshot of the products, keyed by id. It must not be modified
+func (c *Catalog) Products() map[string]*Product {
+	return *c.products.Load()
+}
+
+// Get returns the product with the id
+func (c *Catalog) Get(id string) (*Product, bool) {
+	pr, ok := c.Products()[id]
+	return pr, ok
+}
+
+// Len returns the number of products in the catalog
+func (c *Catalog) Len() int {
+	return len(c.Products())
+}
+
+// Create saves a new product, ErrAlreadyExists if its id is taken
+func (c *Catalog) Create(ctx context.Context, pr *Product) error {
+	return c.write(func() error { return c.store.CreateProduct(ctx, pr) }, func(products map[string]*Product) {
+		products[pr.ID] = pr
+	})
+}
+
+// Update replaces the product with the same id, ErrNotFound if there is none
+func (c *Catalog) Update(ctx context.Context, pr *Product) error {
+	return c.write(func() error { return c.store.UpdateProduct(ctx, pr) }, func(products map[string]*Product) {
+		products[pr.ID] = pr
+	})
+}
+
+// Delete removes the product with the id, ErrNotFound if there is none
+func (c *Catalog) Delete(ctx context.Context, id string) error {
+	return c.write(func() error { return c.store.DeleteProduct(ctx, id) }, func(products map[string]*Product) {
+		delete(products, id)
+	})
+}
+
+// Patch applies the change to a copy of the product with the id and saves it, ErrNotFound if there is none.
+// The product is read, changed and saved under the lock of the writers, so concurrent patches of
+// different fields don't overwrite each other. An error of the change aborts the patch
+func (c *Catalog) Patch(ctx context.Context, id string, change func(*Product) error) (*Product, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// the products of the snapshot are shared, so the change is applied to a copy
+	updated := *current
+	if err := change(&updated); err != nil {
+		return nil, err
+	}
+
+	if err := c.store.UpdateProduct(ctx, &updated); err != nil {
+		return nil, err
+	}
+	c.swap(func(products map[string]*Product) {
+		products[id] = &updated
+	})
+	return &updated, nil
+}
+
+// write applies the change to the store and, if it succeeded, to a copy of the snapshot
+func (c *Catalog) write(storeChange func() error, catalogChange func(map[string]*Product)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := storeChange(); err != nil {
+		return err
+	}
+	c.swap(catalogChange)
+	return nil
+}
+
+// swap stores an updated copy of the snapshot, the writers' lock must be held
+func (c *Catalog) swap(catalogChange func(map[string]*Product)) {
+	current := c.Products()
+	products := make(map[string]*Product, len(current)+1)
+	for id, pr := range current {
+		products[id] = pr
+	}
+	catalogChange(products)
+	c.products.Store(&products)
+}
@@ -0,0 +1,115 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogWritesThrough(t *testing.T) {
+	store := NewMemoryStore(&Product{ID: "p1", Name: "Product 1"})
+	catalog, err := NewCatalog(context.Background(), store)
+	require.NoError(t, err)
+
+	snapshot := catalog.Products()
+
+	assert.NoError(t, catalog.Create(context.Background(), &Product{ID: "p2", Name: "Product 2"}))
+	assert.ErrorIs(t, catalog.Create(context.Background(), &Product{ID: "p2", Name: "Other"}), ErrAlreadyExists)
+	assert.NoError(t, catalog.Update(context.Background(), &Product{ID: "p1", Name: "Renamed"}))
+	assert.ErrorIs(t, catalog.Delete(context.Background(), "invalid"), ErrNotFound)
+
+	// the store and the catalog hold the same products
+	stored, _ := store.FetchProducts(context.Background())
+	assert.Equal(t, stored, catalog.Products())
+
+	// earlier snapshots are not modified
+	assert.Len(t, snapshot, 1)
+	assert.Equal(t, "Product 1", snapshot["p1"].Name)
+}
+
+func TestCatalogConcurrentWrites(t *testing.T) {
+	catalog, err := NewCatalog(context.Background(), NewMemoryStore())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			catalog.Create(context.Background(), &Product{ID: fmt.Sprint(i), Name: "Product"})
+			catalog.Get(fmt.Sprint(i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, catalog.Len())
+}
+
+// fakeWatcher reports a change for every value sent on its channel
+type fakeWatcher chan struct{}
+
+func (w fakeWatcher) WatchProducts(ctx context.Context, onChange func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w:
+			onChange()
+		}
+	}
+}
+
+func TestCatalogReloads(t *testing.T) {
+	store := NewMemoryStore()
+	catalog, err := NewCatalog(context.Background(), store)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Test case: reload on interval
+	go catalog.RefreshEvery(ctx, 10*time.Millisecond)
+	store.CreateProduct(context.Background(), &Product{ID: "p1", Name: "Product 1"})
+	assert.Eventually(t, func() bool { return catalog.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Test case: reload on change notification
+	watcher := make(fakeWatcher)
+	go catalog.ReloadOnChange(ctx, watcher)
+	store.CreateProduct(context.Background(), &Product{ID: "p2", Name: "Product 2"})
+	watcher <- struct{}{}
+	assert.Eventually(t, func() bool { return catalog.Len() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+// brokenCursorStore fails after reading part of the products, like a cursor timing out
+type brokenCursorStore struct {
+	*MemoryStore
+}
+
+func (s brokenCursorStore) FetchProducts(ctx context.Context) (map[string]*Product, error) {
+	products, _ := s.MemoryStore.FetchProducts(ctx)
+	for id := range products {
+		delete(products, id)
+		break
+	}
+	return products, context.DeadlineExceeded
+}
+
+func TestCatalogReloadFails(t *testing.T) {
+	store := NewMemoryStore(&Product{ID: "p1", Name: "Product 1"}, &Product{ID: "p2", Name: "Product 2"})
+	catalog, err := NewCatalog(context.Background(), store)
+	require.NoError(t, err)
+
+	// Test case: a fetch failing halfway keeps the current products
+	catalog.store = brokenCursorStore{store}
+	assert.ErrorIs(t, catalog.Reload(context.Background()), context.DeadlineExceeded)
+	assert.Equal(t, 2, catalog.Len())
+	_, ok := catalog.Get("p1")
+	assert.True(t, ok)
+	_, ok = catalog.Get("p2")
+	assert.True(t, ok)
+}
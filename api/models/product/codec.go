@@ -0,0 +1,166 @@
+package product
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// supported file formats of the products
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// csvHeader is the header row of the csv files. When decoding the columns can come in any order
+// and only id and name are required
+var csvHeader = []string{"id", "name", "category", "description", "image_url", "price", "currency", "active"}
+
+// FormatFromPath guesses the format from the extension of the file, json if it is unknown
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return FormatJSON
+}
+
+// Decode reads the products from r in the passed format
+func Decode(r io.Reader, format string) ([]*Product, error) {
+	switch format {
+	case FormatJSON:
+		var products []*Product
+		if err := json.NewDecoder(r).Decode(&products); err != nil {
+			return nil, err
+		}
+		return products, nil
+	case FormatNDJSON:
+		return decodeNDJSON(r)
+	case FormatCSV:
+		return decodeCSV(r)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected json, csv or ndjson", format)
+}
+
+// Encode writes the products to w in the passed format
+func Encode(w io.Writer, format string, products []*Product) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(products)
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, pr := range products {
+			if err := encoder.Encode(pr); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, pr := range products {
+			active := ""
+			if pr.Active != nil {
+				active = strconv.FormatBool(*pr.Active)
+			}
+			record := []string{pr.ID, pr.Name, pr.Category, pr.Description, pr.ImageURL,
+				strconv.FormatInt(pr.Price, 10), pr.Currency, active}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown format %q, expected json, csv or ndjson", format)
+}
+
+func decodeNDJSON(r io.Reader) ([]*Product, error) {
+	products := make([]*Product, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		pr := &Product{}
+		if err := json.Unmarshal(scanner.Bytes(), pr); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		products = append(products, pr)
+	}
+	return products, scanner.Err()
+}
+
+func decodeCSV(r io.Reader) ([]*Product, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the csv header: %w", err)
+	}
+
+	// position of each column
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"id", "name"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the csv header has no %q column", name)
+		}
+	}
+
+	products := make([]*Product, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return products, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// value of the column, empty if the file does not have it
+		value := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		pr := &Product{
+			ID:          value("id"),
+			Name:        value("name"),
+			Category:    value("category"),
+			Description: value("description"),
+			ImageURL:    value("image_url"),
+			Currency:    value("currency"),
+		}
+		if price := value("price"); price != "" {
+			if pr.Price, err = strconv.ParseInt(price, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid price %q", line, price)
+			}
+		}
+		if active := value("active"); active != "" {
+			isActive, err := strconv.ParseBool(active)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid active %q", line, active)
+			}
+			pr.Active = &isActive
+		}
+		products = append(products, pr)
+	}
+}
@@ -0,0 +1,44 @@
+package product
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	active := true
+	products := []*Product{
+		{ID: "1", Name: "Lemonade, Schweppes"},
+		{ID: "2", Name: `Red "Bull"`, Category: "drinks", Description: "Energy drink",
+			ImageURL: "https://example.com/redbull.png", Price: 250, Currency: "EUR", Active: &active},
+	}
+
+	for _, format := range []string{FormatJSON, FormatCSV, FormatNDJSON} {
+		var buf bytes.Buffer
+		assert.NoError(t, Encode(&buf, format, products), format)
+
+		decoded, err := Decode(&buf, format)
+		assert.NoError(t, err, format)
+		assert.Equal(t, products, decoded, format)
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	// Test case: columns in any order
+	products, err := Decode(strings.NewReader("name,id\nRed Bull,2\n"), FormatCSV)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Product{{ID: "2", Name: "Red Bull"}}, products)
+
+	// Test case: missing column
+	_, err = Decode(strings.NewReader("id\n2\n"), FormatCSV)
+	assert.Error(t, err)
+}
+
+func TestFormatFromPath(t *testing.T) {
+	assert.Equal(t, FormatJSON, FormatFromPath("products.json"))
+	assert.Equal(t, FormatCSV, FormatFromPath("products.CSV"))
+	assert.Equal(t, FormatNDJSON, FormatFromPath("products.ndjson"))
+}
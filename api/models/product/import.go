@@ -0,0 +1,88 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Diff is the difference between a list of products and the ones saved in a store
+type Diff struct {
+	Added     []*Product
+	Changed   []*Product
+	Unchanged []*Product
+	// Missing are saved but not in the list; importing never removes them
+	Missing []*Product
+}
+
+// ValidateAll checks every product of the list, including that no id appears twice
+func ValidateAll(products []*Product) []error {
+	var errs []error
+	seen := make(map[string]int, len(products))
+	for i, pr := range products {
+		if err := pr.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("product #%d (id %q): %w", i+1, pr.ID, err))
+			continue
+		}
+		if first, ok := seen[pr.ID]; ok {
+			errs = append(errs, fmt.Errorf("product #%d: id %q is already used by product #%d", i+1, pr.ID, first))
+			continue
+		}
+		seen[pr.ID] = i + 1
+	}
+	return errs
+}
+
+// ComputeDiff compares the products against the saved ones, matching them by id
+func ComputeDiff(saved map[string]*Product, products []*Product) *Diff {
+	diff := &Diff{}
+	listed := make(map[string]bool, len(products))
+	for _, pr := range products {
+		listed[pr.ID] = true
+
+		current, ok := saved[pr.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, pr)
+		case !current.Equal(pr):
+			diff.Changed = append(diff.Changed, pr)
+		default:
+			diff.Unchanged = append(diff.Unchanged, pr)
+		}
+	}
+
+	for id, pr := range saved {
+		if !listed[id] {
+			diff.Missing = append(diff.Missing, pr)
+		}
+	}
+	sort.Slice(diff.Missing, func(i, j int) bool { return diff.Missing[i].ID < diff.Missing[j].ID })
+	return diff
+}
+
+// Import upserts the products into the store by id; new products are created and changed ones
+// are updated, so importing the same list twice changes nothing. The products are expected to be
+// validated with ValidateAll. With dryRun the diff is computed but the store is left untouched
+func Import(ctx context.Context, store Store, products []*Product, dryRun bool) (*Diff, error) {
+	saved, err := store.FetchProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := ComputeDiff(saved, products)
+	if dryRun {
+		return diff, nil
+	}
+
+	for _, pr := range diff.Added {
+		if err := store.CreateProduct(ctx, pr); err != nil {
+			return nil, fmt.Errorf("creating product %q: %w", pr.ID, err)
+		}
+	}
+	for _, pr := range diff.Changed {
+		if err := store.UpdateProduct(ctx, pr); err != nil {
+			return nil, fmt.Errorf("updating product %q: %w", pr.ID, err)
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,52 @@
+package product
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAll(t *testing.T) {
+	errs := ValidateAll([]*Product{
+		{ID: "1", Name: "Product 1"},
+		{ID: "1", Name: "Duplicated"},
+		{ID: "", Name: "No id"},
+	})
+	assert.Len(t, errs, 2)
+}
+
+func TestImport(t *testing.T) {
+	store := NewMemoryStore(
+		&Product{ID: "1", Name: "Product 1"},
+		&Product{ID: "2", Name: "Product 2"},
+	)
+	products := []*Product{
+		{ID: "1", Name: "Product 1"},
+		{ID: "2", Name: "Renamed"},
+		{ID: "3", Name: "Product 3"},
+	}
+
+	// Test case: dry run leaves the store untouched
+	diff, err := Import(context.Background(), store, products, true)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Added, 1)
+	assert.Len(t, diff.Changed, 1)
+	assert.Len(t, diff.Unchanged, 1)
+	saved, _ := store.FetchProducts(context.Background())
+	assert.Len(t, saved, 2)
+
+	// Test case: import upserts by id
+	_, err = Import(context.Background(), store, products, false)
+	assert.NoError(t, err)
+	saved, _ = store.FetchProducts(context.Background())
+	assert.Len(t, saved, 3)
+	assert.Equal(t, "Renamed", saved["2"].Name)
+
+	// Test case: importing again changes nothing
+	diff, err = Import(context.Background(), store, products, false)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Unchanged, 3)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Changed)
+}
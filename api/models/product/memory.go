@@ -0,0 +1,76 @@
+package product
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-memory implementation of Store, safe for concurrent use.
+// It is meant for running the API locally or in integration tests without a database
+type MemoryStore struct {
+	mu       sync.RWMutex
+	products map[string]*Product
+}
+
+// NewMemoryStore creates an in-memory product store holding the passed products
+func NewMemoryStore(products ...*Product) *MemoryStore {
+	store := &MemoryStore{
+		products: make(map[string]*Product, len(products)),
+	}
+	for _, pr := range products {
+		cp := *pr
+		store.products[pr.ID] = &cp
+	}
+	return store
+}
+
+// FetchProducts returns a copy of the products in the store
+func (m *MemoryStore) FetchProducts(ctx context.Context) (map[string]*Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	products := make(map[string]*Product, len(m.products))
+	for id, pr := range m.products {
+		cp := *pr
+		products[id] = &cp
+	}
+	return products, nil
+}
+
+// CreateProduct saves a copy of the product if no product has its id
+func (m *MemoryStore) CreateProduct(ctx context.Context, pr *Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[pr.ID]; ok {
+		return ErrAlreadyExists
+	}
+	cp := *pr
+	m.products[pr.ID] = &cp
+	return nil
+}
+
+// UpdateProduct replaces the product with the same id
+func (m *MemoryStore) UpdateProduct(ctx context.Context, pr *Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[pr.ID]; !ok {
+		return ErrNotFound
+	}
+	cp := *pr
+	m.products[pr.ID] = &cp
+	return nil
+}
+
+// DeleteProduct removes the product with the id
+func (m *MemoryStore) DeleteProduct(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.products[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.products, id)
+	return nil
+}
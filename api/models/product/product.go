@@ -1,72 +1,163 @@
 package product
 
 import (
+	"api_assignment/api/database"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // Product is simple struct that represents a product with its field, like ids, and name
 type Product struct {
-	ID   string `json:"id" bson:"id"`
-	Name string `json:"name" bson:"name"`
+	ID          string `json:"id" bson:"id"`
+	Name        string `json:"name" bson:"name"`
+	Category    string `json:"category,omitempty" bson:"category,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty" bson:"image_url,omitempty"`
+	// Price is in minor units of the currency, e.g. cents
+	Price int64 `json:"price,omitempty" bson:"price,omitempty"`
+	// Currency is the ISO 4217 code of the price, e.g. EUR
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty"`
+	// Active tells whether the product can be voted for.
+	// It is nil for products saved before the flag existed, which are active
+	Active *bool `json:"active,omitempty" bson:"active,omitempty"`
 }
 
-func AddProductsToDB(DB *mongo.Client) (map[string]*Product, error) {
-	// Open the JSON file
-	file, err := os.Open("products.json")
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil, err
+// IsActive tells whether the product can be voted for
+func (pr *Product) IsActive() bool {
+	return pr.Active == nil || *pr.Active
+}
+
+// Equal tells whether both products hold the same data
+func (pr *Product) Equal(other *Product) bool {
+	a, b := *pr, *other
+	a.Active, b.Active = nil, nil
+	return a == b && pr.IsActive() == other.IsActive()
+}
+
+// errors returned by the stores
+var (
+	ErrNotFound      = errors.New("product not found")
+	ErrAlreadyExists = errors.New("product already exists")
+)
+
+// Store is the contract every product storage backend has to fulfil
+type Store interface {
+	FetchProducts(ctx context.Context) (map[string]*Product, error)
+	// CreateProduct returns ErrAlreadyExists if a product with the same id exists
+	CreateProduct(ctx context.Context, pr *Product) error
+	// UpdateProduct replaces the product with the same id, ErrNotFound if there is none
+	UpdateProduct(ctx context.Context, pr *Product) error
+	// DeleteProduct returns ErrNotFound if there is no product with the id
+	DeleteProduct(ctx context.Context, id string) error
+}
+
+// Watcher is implemented by the stores that can notify about changes of the products
+type Watcher interface {
+	// WatchProducts calls onChange after every change of the products until the context is
+	// cancelled or the watch fails
+	WatchProducts(ctx context.Context, onChange func()) error
+}
+
+// Validate checks the product can be saved
+func (pr *Product) Validate() error {
+	if strings.TrimSpace(pr.ID) == "" {
+		return errors.New("id must not be empty")
 	}
-	defer file.Close() // Ensure the file is closed after reading
+	if strings.TrimSpace(pr.Name) == "" {
+		return errors.New("name must not be empty")
+	}
+	if pr.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if pr.Price > 0 && !currencyPattern.MatchString(pr.Currency) {
+		return errors.New("currency must be a 3 letter ISO 4217 code, e.g. EUR")
+	}
+	if pr.ImageURL != "" {
+		if u, err := url.Parse(pr.ImageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("image_url must be an absolute http(s) url")
+		}
+	}
+	return nil
+}
 
-	var products []*Product
+// currencyPattern matches ISO 4217 currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
 
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&products)
+// ReadProductsFile reads the list of products from a file like products.json.
+// The format (json, csv or ndjson) is picked from the extension of the file
+func ReadProductsFile(path string) ([]*Product, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Println("Error decoding JSON:", err)
 		return nil, err
 	}
+	defer file.Close() // Ensure the file is closed after reading
 
-	for _, pr := range products {
-		fmt.Printf("%+v\n", pr)
-	}
-
-	coll := DB.Database("trial").Collection("products")
-
-	productsInterface := make([]interface{}, len(products))
-	for i, pr := range products {
-		productsInterface[i] = pr
+	products, err := Decode(file, FormatFromPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
 	}
-	_, err = coll.InsertMany(context.TODO(), productsInterface)
+	return products, nil
+}
 
+// AddProductsToDB imports the products of the file into database.DefaultMongoDB and returns the saved products.
+// Products are upserted by id so running it again does not duplicate them
+func AddProductsToDB(ctx context.Context, DB *mongo.Client, path string) (map[string]*Product, error) {
+	products, err := ReadProductsFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	if errs := ValidateAll(products); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 
+	pModel := ProductModel{DB: DB}
+	if _, err := Import(ctx, pModel, products, false); err != nil {
+		return nil, err
+	}
+	return pModel.FetchProducts(ctx)
 }
 
-// FetchProducts calls the endpoint and return the products from there
-func FetchProducts(DB *mongo.Client) (map[string]*Product, error) {
+// tracer traces the loading of the products
+var tracer = otel.Tracer("api_assignment/api/models/product")
 
-	coll := DB.Database("trial").Collection("products")
+// FetchProducts calls the endpoint and return the products from there.
+// The products are read from database.DefaultMongoDB, ProductModel reads them from the db it is configured with
+func FetchProducts(ctx context.Context, DB *mongo.Client) (map[string]*Product, error) {
+	return fetchProducts(ctx, database.MongoDatabase(DB, "").Collection("products"))
+}
+
+// fetchProducts returns every product of the collection
+func fetchProducts(ctx context.Context, coll *mongo.Collection) (map[string]*Product, error) {
+	ctx, span := tracer.Start(ctx, "FetchProducts")
+	defer span.End()
 
 	// fetch everything
 	filter := bson.D{{}}
 
 	var foundProducts []*Product
-	cur, err := coll.Find(context.TODO(), filter)
+	cur, err := coll.Find(ctx, filter)
+	if err == nil {
+		// a cursor failing halfway would return a partial catalog
+		err = cur.All(ctx, &foundProducts)
+	}
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
-	cur.All(context.TODO(), &foundProducts)
+	span.SetAttributes(attribute.Int("products.count", len(foundProducts)))
 
 	// holder of products
 	products := make(map[string]*Product)
@@ -76,10 +167,7 @@ func FetchProducts(DB *mongo.Client) (map[string]*Product, error) {
 		products[product.ID] = product
 	}
 
-	fmt.Println("Fetched the following products:")
-	for id, pr := range products {
-		fmt.Printf("%s : %+v\n", id, pr)
-	}
+	slog.Debug("fetched the products", "count", len(products))
 
 	return products, nil
 }
@@ -0,0 +1,123 @@
+package product
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProductModel is the MongoDB implementation of Store
+type ProductModel struct {
+	DB *mongo.Client
+	// DBName is the name of the database holding the collection, database.DefaultMongoDB when empty
+	DBName string
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+func (pModel ProductModel) collection() *mongo.Collection {
+	return database.MongoDatabase(pModel.DB, pModel.DBName).Collection("products")
+}
+
+// FetchProducts returns the products saved in the db
+func (pModel ProductModel) FetchProducts(ctx context.Context) (map[string]*Product, error) {
+	ctx, cancel := database.WithTimeout(ctx, pModel.Timeout)
+	defer cancel()
+
+	return fetchProducts(ctx, pModel.collection())
+}
+
+// EnsureIndexes creates the unique index on the ids of the products, so two instances of the api,
+// or the api and productctl, can't both create a product with the same id
+func (pModel ProductModel) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx, pModel.Timeout)
+	defer cancel()
+
+	_, err := pModel.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// CreateProduct inserts the product only if no product has its id.
+// $setOnInsert with an upsert does the check and the insert in a single operation,
+// the unique index rejects the insert of a concurrent upsert of the same id
+func (pModel ProductModel) CreateProduct(ctx context.Context, pr *Product) error {
+	ctx, cancel := database.WithTimeout(ctx, pModel.Timeout)
+	defer cancel()
+
+	coll := pModel.collection()
+
+	filter := bson.D{{Key: "id", Value: pr.ID}}
+	update := bson.D{{Key: "$setOnInsert", Value: pr}}
+	opts := options.Update().SetUpsert(true)
+
+	result, err := coll.UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// UpdateProduct replaces the product with the same id
+func (pModel ProductModel) UpdateProduct(ctx context.Context, pr *Product) error {
+	ctx, cancel := database.WithTimeout(ctx, pModel.Timeout)
+	defer cancel()
+
+	coll := pModel.collection()
+
+	result, err := coll.ReplaceOne(ctx, bson.D{{Key: "id", Value: pr.ID}}, pr)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteProduct removes the product with the id
+func (pModel ProductModel) DeleteProduct(ctx context.Context, id string) error {
+	ctx, cancel := database.WithTimeout(ctx, pModel.Timeout)
+	defer cancel()
+
+	coll := pModel.collection()
+
+	result, err := coll.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WatchProducts follows the change stream of the products collection.
+// Change streams need a replica set, like the ones of atlas
+func (pModel ProductModel) WatchProducts(ctx context.Context, onChange func()) error {
+	coll := pModel.collection()
+
+	stream, err := coll.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		return err
+	}
+	// the context may be cancelled already, the stream still has to be closed on the server
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		onChange()
+	}
+	return stream.Err()
+}
@@ -0,0 +1,118 @@
+package product
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SQLStore is the database/sql implementation of Store, it works with both sqlite and postgres
+type SQLStore struct {
+	DB *database.DB
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+// FetchProducts returns the products saved in the db
+func (s SQLStore) FetchProducts(ctx context.Context) (map[string]*Product, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT product_id, product_name, category, description, image_url, price, currency, active
+		FROM products`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[string]*Product)
+	for rows.Next() {
+		pr := &Product{}
+		var active sql.NullBool
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.Category, &pr.Description, &pr.ImageURL, &pr.Price, &pr.Currency, &active); err != nil {
+			return nil, err
+		}
+		if active.Valid {
+			pr.Active = &active.Bool
+		}
+		products[pr.ID] = pr
+	}
+	return products, rows.Err()
+}
+
+// AddProducts saves the passed products, products whose id already exists are left untouched
+// so seeding the db on every start is safe
+func (s SQLStore) AddProducts(ctx context.Context, products []*Product) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, pr := range products {
+		if _, err := tx.ExecContext(ctx, s.DB.Rebind(insertProduct), productArgs(pr)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateProduct inserts the product if no product has its id
+func (s SQLStore) CreateProduct(ctx context.Context, pr *Product) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, s.DB.Rebind(insertProduct), productArgs(pr)...)
+	return checkAffected(result, err, ErrAlreadyExists)
+}
+
+// UpdateProduct replaces the product with the same id
+func (s SQLStore) UpdateProduct(ctx context.Context, pr *Product) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, s.DB.Rebind(`UPDATE products SET product_name = ?, category = ?, description = ?,
+		image_url = ?, price = ?, currency = ?, active = ? WHERE product_id = ?`),
+		append(productArgs(pr)[1:], pr.ID)...)
+	return checkAffected(result, err, ErrNotFound)
+}
+
+// DeleteProduct removes the product with the id
+func (s SQLStore) DeleteProduct(ctx context.Context, id string) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, s.DB.Rebind(`DELETE FROM products WHERE product_id = ?`), id)
+	return checkAffected(result, err, ErrNotFound)
+}
+
+// insertProduct inserts a product unless its id is taken, its args are productArgs
+const insertProduct = `INSERT INTO products (product_id, product_name, category, description, image_url, price, currency, active)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (product_id) DO NOTHING`
+
+// productArgs are the column values of the product, in the order of insertProduct
+func productArgs(pr *Product) []any {
+	active := sql.NullBool{}
+	if pr.Active != nil {
+		active = sql.NullBool{Bool: *pr.Active, Valid: true}
+	}
+	return []any{pr.ID, pr.Name, pr.Category, pr.Description, pr.ImageURL, pr.Price, pr.Currency, active}
+}
+
+// checkAffected returns errNone when the statement went through but touched no row
+func checkAffected(result sql.Result, err error, errNone error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNone
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package product
+
+import (
+	"api_assignment/api/database"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStore(t *testing.T) {
+	db, err := database.Open(database.SQLite, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	store := SQLStore{DB: db}
+
+	inactive := false
+	full := &Product{ID: "1", Name: "Red Bull", Category: "drinks", Description: "Energy drink",
+		ImageURL: "https://example.com/redbull.png", Price: 250, Currency: "EUR", Active: &inactive}
+
+	assert.NoError(t, store.CreateProduct(context.Background(), full))
+	assert.NoError(t, store.CreateProduct(context.Background(), &Product{ID: "2", Name: "Brownie"}))
+	assert.ErrorIs(t, store.CreateProduct(context.Background(), &Product{ID: "2", Name: "Other"}), ErrAlreadyExists)
+
+	products, err := store.FetchProducts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, full, products["1"])
+	// products without the flag are active
+	assert.Nil(t, products["2"].Active)
+	assert.True(t, products["2"].IsActive())
+
+	full.Price = 300
+	assert.NoError(t, store.UpdateProduct(context.Background(), full))
+	assert.ErrorIs(t, store.UpdateProduct(context.Background(), &Product{ID: "3", Name: "Unknown"}), ErrNotFound)
+	products, _ = store.FetchProducts(context.Background())
+	assert.Equal(t, int64(300), products["1"].Price)
+
+	assert.NoError(t, store.DeleteProduct(context.Background(), "1"))
+	assert.ErrorIs(t, store.DeleteProduct(context.Background(), "1"), ErrNotFound)
+}
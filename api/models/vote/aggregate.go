@@ -0,0 +1,39 @@
+package vote
+
+import "context"
+
+// Aggregate is the running total of the votes of a single product.
+// It is kept up to date on every vote so the avgs don't have to be recomputed from the raw votes
+type Aggregate struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	Sum       int    `json:"sum" bson:"sum"`
+	Count     int    `json:"count" bson:"count"`
+	// Histogram holds the number of votes of each rate; index 0 is rate 1 and index 9 is rate 10
+	Histogram [10]int `json:"histogram" bson:"histogram"`
+}
+
+// Reconciler is implemented by the stores that keep aggregates, to rebuild them from the raw votes
+// in case they drifted (e.g. a crash between saving a vote and updating its aggregate)
+type Reconciler interface {
+	ReconcileAggregates(ctx context.Context) error
+}
+
+// ProductVote converts the aggregate to the avg representation returned by the api
+func (a *Aggregate) ProductVote() *ProductVote {
+	pv := &ProductVote{Sum: a.Sum, VotesCount: a.Count}
+	if a.Count == 0 {
+		return pv
+	}
+
+	pv.Avg = float64(a.Sum) / float64(a.Count)
+	for i, n := range a.Histogram {
+		if n == 0 {
+			continue
+		}
+		if pv.Min == 0 {
+			pv.Min = i + 1
+		}
+		pv.Max = i + 1
+	}
+	return pv
+}
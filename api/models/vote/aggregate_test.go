@@ -0,0 +1,28 @@
+package vote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateProductVote(t *testing.T) {
+	agg := &Aggregate{ProductID: "p1"}
+
+	// Test case: no votes
+	assert.Equal(t, &ProductVote{}, agg.ProductVote())
+
+	// Test case: avg, min and max derived from the histogram
+	agg.Sum, agg.Count = 21, 3
+	agg.Histogram = [10]int{0, 0, 1, 0, 0, 0, 0, 1, 0, 1}
+	assert.Equal(t, &ProductVote{Avg: 7, VotesCount: 3, Sum: 21, Min: 3, Max: 10}, agg.ProductVote())
+}
+
+func TestAggregateDoc(t *testing.T) {
+	doc := &aggregateDoc{ProductID: "p1", Sum: 12, Count: 2, Histogram: map[string]int{"2": 1, "10": 1}}
+
+	agg := doc.aggregate()
+	assert.Equal(t, [10]int{0, 1, 0, 0, 0, 0, 0, 0, 0, 1}, agg.Histogram)
+	assert.Equal(t, 12, agg.Sum)
+	assert.Equal(t, 2, agg.Count)
+}
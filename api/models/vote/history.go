@@ -0,0 +1,35 @@
+package vote
+
+import "time"
+
+// VoteChange records a change of the rate of a vote.
+// The history of the votes is append-only, a change is never updated nor removed
+type VoteChange struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	SessionID string `json:"session_id" bson:"session_id"`
+	// OldRate is nil when the vote was first cast
+	OldRate *int `json:"old_rate" bson:"old_rate"`
+	// NewRate is 0 when the vote was deleted
+	NewRate   int       `json:"new_rate,omitempty" bson:"new_rate"`
+	Deleted   bool      `json:"deleted,omitempty" bson:"deleted,omitempty"`
+	ChangedAt time.Time `json:"changed_at" bson:"changed_at"`
+}
+
+// newVoteChange returns the change from oldVote to newVote, either of them is nil for a new or a
+// deleted vote. It is nil when the vote was posted again with the same rate
+func newVoteChange(oldVote, newVote *VoteResult, at time.Time) *VoteChange {
+	change := &VoteChange{ChangedAt: at}
+	if newVote != nil {
+		change.ProductID, change.SessionID, change.NewRate = newVote.ProductID, newVote.SessionID, newVote.Rate
+	} else {
+		change.ProductID, change.SessionID, change.Deleted = oldVote.ProductID, oldVote.SessionID, true
+	}
+	if oldVote != nil {
+		if newVote != nil && oldVote.Rate == newVote.Rate {
+			return nil
+		}
+		oldRate := oldVote.Rate
+		change.OldRate = &oldRate
+	}
+	return change
+}
@@ -0,0 +1,104 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historian is the part of the stores tested by the history tests
+type historian interface {
+	PostVote(context.Context, *VoteResult) (*bool, error)
+	GetVoteHistory(ctx context.Context, productID string) ([]*VoteChange, error)
+}
+
+// testVoteHistory runs the history tests against a store
+func testVoteHistory(t *testing.T, store historian) {
+	for _, v := range []*VoteResult{
+		{ProductID: "p1", SessionID: "s1", Rate: 4},
+		{ProductID: "p2", SessionID: "s1", Rate: 7},
+		{ProductID: "p1", SessionID: "s1", Rate: 9},
+		// same rate again, nothing changed
+		{ProductID: "p1", SessionID: "s1", Rate: 9},
+		{ProductID: "p1", SessionID: "s2", Rate: 2},
+	} {
+		_, err := store.PostVote(context.Background(), v)
+		require.NoError(t, err)
+	}
+
+	changes, err := store.GetVoteHistory(context.Background(), "p1")
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	rate := func(r int) *int { return &r }
+	for i, expected := range []VoteChange{
+		{ProductID: "p1", SessionID: "s1", OldRate: nil, NewRate: 4},
+		{ProductID: "p1", SessionID: "s1", OldRate: rate(4), NewRate: 9},
+		{ProductID: "p1", SessionID: "s2", OldRate: nil, NewRate: 2},
+	} {
+		assert.False(t, changes[i].ChangedAt.IsZero())
+		expected.ChangedAt = changes[i].ChangedAt
+		assert.Equal(t, &expected, changes[i])
+	}
+
+	// Test case: no changes for an unknown product
+	changes, err = store.GetVoteHistory(context.Background(), "unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestMemoryStoreVoteHistory(t *testing.T) {
+	testVoteHistory(t, NewMemoryStore())
+}
+
+func TestSQLStoreVoteHistory(t *testing.T) {
+	testVoteHistory(t, newTestSQLStore(t))
+}
+
+// testDeleteVote runs the deletion tests against a store
+func testDeleteVote(t *testing.T, store Store) {
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s2", Rate: 9})
+
+	// Test case: the vote of the session is removed, the others are kept
+	require.NoError(t, store.DeleteVote(context.Background(), "p1", "s2"))
+	votes, err := store.GetVotesByProductID(context.Background(), "p1")
+	require.NoError(t, err)
+	require.Len(t, votes, 1)
+	assert.Equal(t, "s1", votes[0].SessionID)
+
+	avgs, err := store.GetAverageVotesForAllProducts(context.Background(), map[string]*product.Product{"p1": {ID: "p1"}})
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, avgs["p1"].Avg)
+	assert.Equal(t, 1, avgs["p1"].VotesCount)
+
+	// Test case: the deletion is in the history
+	changes, err := store.GetVoteHistory(context.Background(), "p1")
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	deletion := changes[2]
+	assert.True(t, deletion.Deleted)
+	assert.Equal(t, "s2", deletion.SessionID)
+	assert.Equal(t, 9, *deletion.OldRate)
+	assert.Zero(t, deletion.NewRate)
+
+	// Test case: no vote to delete
+	assert.ErrorIs(t, store.DeleteVote(context.Background(), "p1", "s2"), ErrNotFound)
+	assert.ErrorIs(t, store.DeleteVote(context.Background(), "p2", "s1"), ErrNotFound)
+
+	// Test case: the session can vote again
+	exists, err := store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s2", Rate: 3})
+	require.NoError(t, err)
+	assert.False(t, *exists)
+}
+
+func TestMemoryStoreDeleteVote(t *testing.T) {
+	testDeleteVote(t, NewMemoryStore())
+}
+
+func TestSQLStoreDeleteVote(t *testing.T) {
+	testDeleteVote(t, newTestSQLStore(t))
+}
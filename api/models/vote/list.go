@@ -0,0 +1,206 @@
+package vote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// sort fields of the vote listings
+const (
+	// SortTime orders the votes by the time they were first cast
+	SortTime = "time"
+	SortRate = "rate"
+)
+
+// limits of the page size
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// ErrInvalidCursor is returned when the cursor is malformed or was issued for another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions filters, sorts and paginates the votes of ListVotes
+type ListOptions struct {
+	// ProductID and SessionID only keep the votes of the product or session when set
+	ProductID string
+	SessionID string
+	// MinRate and MaxRate bound the rates, 0 means no bound
+	MinRate int
+	MaxRate int
+	// Sort is SortTime (default) or SortRate, ties are ordered by product and session
+	Sort string
+	Desc bool
+	// Limit is the size of the page, DefaultListLimit when 0 and at most MaxListLimit
+	Limit int
+	// Cursor is the Next of the previous page, empty for the first page
+	Cursor string
+}
+
+// Page is a page of votes
+type Page struct {
+	Votes []*VoteResult
+	// Next is the cursor of the next page, empty on the last page
+	Next string
+}
+
+// cursor holds the sort values of the last vote of a page, the next page starts right after it.
+// It is handed to the clients base64 encoded, so they treat it as opaque
+type cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Rate int    `json:"r,omitempty"`
+	// Time is the creation time of the vote, for the stores sorting by it
+	Time time.Time `json:"t,omitempty"`
+	// ID is the id of the vote in the store, for the stores sorting by it (e.g. mongo's _id)
+	ID        string `json:"i,omitempty"`
+	ProductID string `json:"p"`
+	SessionID string `json:"u"`
+}
+
+// normalize fills the defaults of the options and decodes their cursor, nil for the first page
+func (opts *ListOptions) normalize() (*cursor, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortTime
+	}
+	if opts.Sort != SortTime && opts.Sort != SortRate {
+		return nil, errors.New("sort must be time or rate")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultListLimit
+	}
+	opts.Limit = min(opts.Limit, MaxListLimit)
+
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cur := &cursor{}
+	if err := json.Unmarshal(raw, cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cur.Sort != opts.Sort || cur.Desc != opts.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// matches tells whether the vote passes the filters of the options
+func (opts *ListOptions) matches(v *VoteResult) bool {
+	return (opts.ProductID == "" || v.ProductID == opts.ProductID) &&
+		(opts.SessionID == "" || v.SessionID == opts.SessionID) &&
+		(opts.MinRate == 0 || v.Rate >= opts.MinRate) &&
+		(opts.MaxRate == 0 || v.Rate <= opts.MaxRate)
+}
+
+// newCursor creates the cursor pointing right after the vote
+func (opts *ListOptions) newCursor(v *VoteResult, id string) *cursor {
+	cur := &cursor{Sort: opts.Sort, Desc: opts.Desc, ID: id, ProductID: v.ProductID, SessionID: v.SessionID}
+	if opts.Sort == SortRate {
+		cur.Rate = v.Rate
+	} else if v.CreatedAt != nil {
+		cur.Time = *v.CreatedAt
+	}
+	return cur
+}
+
+// encode returns the opaque token of the cursor
+func (cur *cursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// compareVotes orders two votes by the sort of the options, ties are broken by product and session
+func compareVotes(a, b *VoteResult, sortBy string) int {
+	if sortBy == SortRate {
+		if a.Rate != b.Rate {
+			return a.Rate - b.Rate
+		}
+	} else {
+		if c := createdAt(a).Compare(createdAt(b)); c != 0 {
+			return c
+		}
+	}
+	if a.ProductID != b.ProductID {
+		if a.ProductID < b.ProductID {
+			return -1
+		}
+		return 1
+	}
+	if a.SessionID < b.SessionID {
+		return -1
+	} else if a.SessionID > b.SessionID {
+		return 1
+	}
+	return 0
+}
+
+// createdAt returns the creation time of the vote, zero if unknown
+func createdAt(v *VoteResult) time.Time {
+	if v.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *v.CreatedAt
+}
+
+// listVotes applies the options to the votes in Go, for the stores that can't do it on the db side
+func listVotes(votes []*VoteResult, opts ListOptions) (*Page, error) {
+	after, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	// the cursor as a vote, so it can be compared like one
+	var last *VoteResult
+	if after != nil {
+		last = &VoteResult{Rate: after.Rate, ProductID: after.ProductID, SessionID: after.SessionID}
+		if !after.Time.IsZero() {
+			last.CreatedAt = &after.Time
+		}
+	}
+
+	order := 1
+	if opts.Desc {
+		order = -1
+	}
+
+	found := make([]*VoteResult, 0)
+	for _, v := range votes {
+		if !opts.matches(v) {
+			continue
+		}
+		if last != nil && compareVotes(v, last, opts.Sort)*order <= 0 {
+			continue
+		}
+		found = append(found, v)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return compareVotes(found[i], found[j], opts.Sort)*order < 0
+	})
+
+	return newPage(found, &opts, nil), nil
+}
+
+// newPage trims the votes to the limit of the options and points the next cursor at the last one.
+// The stores fetch one vote past the limit to know whether there is a next page; ids are the
+// store ids of the votes when the cursor needs them
+func newPage(votes []*VoteResult, opts *ListOptions, ids []string) *Page {
+	if len(votes) <= opts.Limit {
+		return &Page{Votes: votes}
+	}
+
+	votes = votes[:opts.Limit]
+	last := len(votes) - 1
+	id := ""
+	if ids != nil {
+		id = ids[last]
+	}
+	return &Page{Votes: votes, Next: opts.newCursor(votes[last], id).encode()}
+}
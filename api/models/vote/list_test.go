@@ -0,0 +1,118 @@
+package vote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lister is the part of the stores tested by the listing tests
+type lister interface {
+	PostVote(context.Context, *VoteResult) (*bool, error)
+	ListVotes(context.Context, ListOptions) (*Page, error)
+}
+
+// testListVotes runs the listing tests against a store, so every store paginates the same way
+func testListVotes(t *testing.T, newStore func(t *testing.T) lister) {
+	seed := func(t *testing.T) lister {
+		store := newStore(t)
+		for i, v := range []*VoteResult{
+			{ProductID: "p1", SessionID: "s1", Rate: 4},
+			{ProductID: "p2", SessionID: "s1", Rate: 9},
+			{ProductID: "p1", SessionID: "s2", Rate: 9},
+			{ProductID: "p3", SessionID: "s2", Rate: 1},
+			{ProductID: "p2", SessionID: "s3", Rate: 6},
+		} {
+			_, err := store.PostVote(context.Background(), v)
+			require.NoError(t, err, "vote %d", i)
+			// distinct creation times, so the time order is the insertion order
+			time.Sleep(2 * time.Millisecond)
+		}
+		return store
+	}
+
+	// keys returns the product and session of the votes
+	keys := func(votes []*VoteResult) []string {
+		found := make([]string, 0, len(votes))
+		for _, v := range votes {
+			found = append(found, v.ProductID+"/"+v.SessionID)
+		}
+		return found
+	}
+
+	// all follows the cursors until the last page
+	all := func(t *testing.T, store lister, opts ListOptions) [][]string {
+		var pages [][]string
+		for {
+			page, err := store.ListVotes(context.Background(), opts)
+			require.NoError(t, err)
+			pages = append(pages, keys(page.Votes))
+			if page.Next == "" {
+				return pages
+			}
+			require.Less(t, len(pages), 10, "pagination does not end")
+			opts.Cursor = page.Next
+		}
+	}
+
+	t.Run("time order across pages", func(t *testing.T) {
+		store := seed(t)
+		assert.Equal(t, [][]string{
+			{"p1/s1", "p2/s1"},
+			{"p1/s2", "p3/s2"},
+			{"p2/s3"},
+		}, all(t, store, ListOptions{Limit: 2}))
+	})
+
+	t.Run("time order descending", func(t *testing.T) {
+		store := seed(t)
+		assert.Equal(t, [][]string{
+			{"p2/s3", "p3/s2", "p1/s2"},
+			{"p2/s1", "p1/s1"},
+		}, all(t, store, ListOptions{Limit: 3, Desc: true}))
+	})
+
+	t.Run("rate order with ties", func(t *testing.T) {
+		store := seed(t)
+		assert.Equal(t, [][]string{
+			{"p3/s2", "p1/s1"},
+			{"p2/s3", "p1/s2"},
+			{"p2/s1"},
+		}, all(t, store, ListOptions{Sort: SortRate, Limit: 2}))
+		assert.Equal(t, [][]string{
+			{"p2/s1", "p1/s2", "p2/s3"},
+			{"p1/s1", "p3/s2"},
+		}, all(t, store, ListOptions{Sort: SortRate, Desc: true, Limit: 3}))
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		store := seed(t)
+		assert.Equal(t, [][]string{{"p2/s1", "p1/s2", "p2/s3"}}, all(t, store, ListOptions{MinRate: 6}))
+		assert.Equal(t, [][]string{{"p1/s1", "p3/s2"}}, all(t, store, ListOptions{MaxRate: 5}))
+		assert.Equal(t, [][]string{{"p1/s2"}, {"p3/s2"}}, all(t, store, ListOptions{SessionID: "s2", Limit: 1}))
+		assert.Equal(t, [][]string{{"p2/s3", "p2/s1"}}, all(t, store, ListOptions{ProductID: "p2", Sort: SortRate, MinRate: 6}))
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		store := seed(t)
+		_, err := store.ListVotes(context.Background(), ListOptions{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		// a cursor of another sort can't be reused
+		page, err := store.ListVotes(context.Background(), ListOptions{Limit: 1})
+		require.NoError(t, err)
+		_, err = store.ListVotes(context.Background(), ListOptions{Limit: 1, Sort: SortRate, Cursor: page.Next})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestMemoryStoreListVotes(t *testing.T) {
+	testListVotes(t, func(t *testing.T) lister { return NewMemoryStore() })
+}
+
+func TestSQLStoreListVotes(t *testing.T) {
+	testListVotes(t, func(t *testing.T) lister { return newTestSQLStore(t) })
+}
@@ -0,0 +1,167 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"context"
+	"sync"
+	"time"
+)
+
+// voteKey identifies a vote the same way the db does; one vote per product per session
+type voteKey struct {
+	productID string
+	sessionID string
+}
+
+// MemoryStore is an in-memory implementation of Store, safe for concurrent use.
+// It is meant for running the API locally or in integration tests without a database
+type MemoryStore struct {
+	mu    sync.RWMutex
+	votes map[voteKey]*VoteResult
+	// order keeps the insertion order so listings are stable, like the natural order of a collection
+	order []voteKey
+	// history holds the changes of the votes, append-only
+	history []*VoteChange
+}
+
+// NewMemoryStore creates an empty in-memory vote store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		votes: make(map[voteKey]*VoteResult),
+	}
+}
+
+// AllVotes returns a copy of every vote in the store
+func (m *MemoryStore) AllVotes(ctx context.Context) ([]*VoteResult, error) {
+	return m.filter(func(*VoteResult) bool { return true }), nil
+}
+
+// PostVote inserts the vote or updates its rate if the session already voted for the product
+func (m *MemoryStore) PostVote(ctx context.Context, newVote *VoteResult) (*bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	key := voteKey{productID: newVote.ProductID, sessionID: newVote.SessionID}
+	existing, alreadyExist := m.votes[key]
+	m.recordChange(existing, newVote, now)
+	if alreadyExist {
+		existing.Rate = newVote.Rate
+		existing.Suspicious = newVote.Suspicious
+		existing.UpdatedAt = &now
+		return &alreadyExist, nil
+	}
+
+	stored := *newVote
+	stored.CreatedAt = &now
+	stored.UpdatedAt = &now
+	m.votes[key] = &stored
+	m.order = append(m.order, key)
+	return &alreadyExist, nil
+}
+
+// DeleteVote removes the vote of the session for the product
+func (m *MemoryStore) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := voteKey{productID: productID, sessionID: sessionID}
+	existing, ok := m.votes[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	m.recordChange(existing, nil, time.Now().UTC())
+	delete(m.votes, key)
+	for i, k := range m.order {
+		if k == key {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// recordChange appends the change from oldVote to newVote to the history, unless the rate stayed the same.
+// It must be called with the lock held
+func (m *MemoryStore) recordChange(oldVote, newVote *VoteResult, at time.Time) {
+	if change := newVoteChange(oldVote, newVote, at); change != nil {
+		m.history = append(m.history, change)
+	}
+}
+
+// GetVoteHistory returns copies of the changes of the votes of the product, oldest first
+func (m *MemoryStore) GetVoteHistory(ctx context.Context, productID string) ([]*VoteChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	changes := make([]*VoteChange, 0)
+	for _, change := range m.history {
+		if change.ProductID == productID {
+			cp := *change
+			changes = append(changes, &cp)
+		}
+	}
+	return changes, nil
+}
+
+// GetVotesBySessionID returns all votes with the specified session id
+func (m *MemoryStore) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*VoteResult, error) {
+	return m.filter(func(v *VoteResult) bool { return v.SessionID == sessionID }), nil
+}
+
+// GetVotesByProductID returns all votes with the specified product id
+func (m *MemoryStore) GetVotesByProductID(ctx context.Context, productID string) ([]*VoteResult, error) {
+	return m.filter(func(v *VoteResult) bool { return v.ProductID == productID }), nil
+}
+
+// GetAverageVotesForAllProducts calculates the avgs of all votes in the store
+func (m *MemoryStore) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*ProductVote, error) {
+	allVotes, err := m.AllVotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return averageVotes(allVotes, products), nil
+}
+
+// ListVotes sorts, filters and paginates the votes in Go
+func (m *MemoryStore) ListVotes(ctx context.Context, opts ListOptions) (*Page, error) {
+	return listVotes(m.filter(opts.matches), opts)
+}
+
+// EachVote calls fn with copies of the votes, in insertion order.
+// The votes are copied first so fn can take its time without holding the lock
+func (m *MemoryStore) EachVote(ctx context.Context, fn func(*VoteResult) error) error {
+	for _, v := range m.filter(func(*VoteResult) bool { return true }) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProductTrend buckets the votes of the product in Go
+func (m *MemoryStore) GetProductTrend(ctx context.Context, productID string, opts TrendOptions) (*ProductTrend, error) {
+	votes, _ := m.GetVotesByProductID(ctx, productID)
+	return computeTrend(productID, votes, opts)
+}
+
+// filter returns copies of the votes matching keep, in insertion order.
+// copies are returned so callers can't mutate the store without holding the lock
+func (m *MemoryStore) filter(keep func(*VoteResult) bool) []*VoteResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := make([]*VoteResult, 0)
+	for _, key := range m.order {
+		v := m.votes[key]
+		if keep(v) {
+			cp := *v
+			found = append(found, &cp)
+		}
+	}
+	return found
+}
@@ -0,0 +1,103 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorePostVote(t *testing.T) {
+	store := NewMemoryStore()
+
+	// Test case: new vote
+	exists, err := store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 4})
+	assert.NoError(t, err)
+	assert.False(t, *exists)
+
+	// Test case: same session and product updates the rate
+	exists, err = store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 9})
+	assert.NoError(t, err)
+	assert.True(t, *exists)
+
+	votes, err := store.GetVotesByProductID(context.Background(), "p1")
+	assert.NoError(t, err)
+	// the creation time is kept by the update, the update time moves on
+	require.Len(t, votes, 1)
+	require.NotNil(t, votes[0].CreatedAt)
+	require.NotNil(t, votes[0].UpdatedAt)
+	assert.False(t, votes[0].UpdatedAt.Before(*votes[0].CreatedAt))
+	assert.Equal(t, []*VoteResult{{ProductID: "p1", SessionID: "s1", Rate: 9, CreatedAt: votes[0].CreatedAt, UpdatedAt: votes[0].UpdatedAt}}, votes)
+
+	// Test case: returned votes are copies
+	votes[0].Rate = 1
+	votes, _ = store.GetVotesBySessionID(context.Background(), "s1")
+	assert.Equal(t, 9, votes[0].Rate)
+}
+
+func TestMemoryStoreAverages(t *testing.T) {
+	store := NewMemoryStore()
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s2", Rate: 9})
+	// suspicious votes are left out of the averages
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s3", Rate: 1, Suspicious: true})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p2", SessionID: "s3", Rate: 1, Suspicious: true})
+
+	avgs, err := store.GetAverageVotesForAllProducts(context.Background(), map[string]*product.Product{
+		"p1": {ID: "p1"},
+		"p2": {ID: "p2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7.5, avgs["p1"].Avg)
+	assert.Equal(t, 2, avgs["p1"].VotesCount)
+	assert.Equal(t, 0, avgs["p2"].VotesCount)
+}
+
+func TestMemoryStoreConcurrentVotes(t *testing.T) {
+	store := NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: fmt.Sprint(i), Rate: 5})
+			store.AllVotes(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	votes, err := store.AllVotes(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, votes, 50)
+}
+
+func TestMemoryStoreEachVote(t *testing.T) {
+	store := NewMemoryStore()
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p2", SessionID: "s1", Rate: 9})
+
+	// Test case: every vote in insertion order
+	var rates []int
+	err := store.EachVote(context.Background(), func(v *VoteResult) error {
+		rates = append(rates, v.Rate)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{6, 9}, rates)
+
+	// Test case: a cancelled context stops the iteration
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err = store.EachVote(ctx, func(v *VoteResult) error {
+		calls++
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
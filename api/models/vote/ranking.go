@@ -0,0 +1,154 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// supported ranking methods
+const (
+	RankingBayesian = "bayesian"
+	RankingWilson   = "wilson"
+)
+
+// defaults used when the ranking options leave them empty
+const (
+	DefaultPriorWeight = 10.0
+	// wilsonZ is the z score of a 95% confidence
+	wilsonZ = 1.96
+)
+
+// RankingOptions configures how the products are scored
+type RankingOptions struct {
+	// Method is either RankingBayesian or RankingWilson, bayesian when empty
+	Method string
+	// PriorMean is the rate a product is assumed to have before it gets any vote,
+	// the mean of all votes is used when it is 0
+	PriorMean float64
+	// PriorWeight is the number of votes the prior mean is worth, DefaultPriorWeight when 0
+	PriorWeight float64
+}
+
+// RankingOptionsFromEnv reads the default options of the ranking from RANKING_METHOD, RANKING_PRIOR_MEAN
+// and RANKING_PRIOR_WEIGHT, all optional. Invalid options are errors, so they fail the startup instead
+// of every ranking request
+func RankingOptionsFromEnv() (RankingOptions, error) {
+	opts := RankingOptions{Method: os.Getenv("RANKING_METHOD")}
+	var err error
+	if priorMean := os.Getenv("RANKING_PRIOR_MEAN"); priorMean != "" {
+		if opts.PriorMean, err = strconv.ParseFloat(priorMean, 64); err != nil {
+			return opts, fmt.Errorf("invalid RANKING_PRIOR_MEAN: %w", err)
+		}
+	}
+	if priorWeight := os.Getenv("RANKING_PRIOR_WEIGHT"); priorWeight != "" {
+		if opts.PriorWeight, err = strconv.ParseFloat(priorWeight, 64); err != nil {
+			return opts, fmt.Errorf("invalid RANKING_PRIOR_WEIGHT: %w", err)
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// Validate checks the options can score the products. The empty options are valid, they select the defaults.
+// A negative prior weight could divide the bayesian score by zero, and a prior mean off the scale of the
+// rates would skew every score
+func (opts RankingOptions) Validate() error {
+	if opts.Method != "" && opts.Method != RankingBayesian && opts.Method != RankingWilson {
+		return fmt.Errorf("the ranking method must be %s or %s, not %q", RankingBayesian, RankingWilson, opts.Method)
+	}
+	// written so NaN fails as well
+	if opts.PriorMean != 0 && !(opts.PriorMean >= 1 && opts.PriorMean <= 10) {
+		return fmt.Errorf("the prior mean of the ranking must be between 1 and 10, not %v", opts.PriorMean)
+	}
+	if !(opts.PriorWeight >= 0) || math.IsInf(opts.PriorWeight, 1) {
+		return fmt.Errorf("the prior weight of the ranking must be a positive number, not %v", opts.PriorWeight)
+	}
+	return nil
+}
+
+// RankedProduct is a product along with its position in the ranking
+type RankedProduct struct {
+	Rank       int     `json:"rank"`
+	ProductID  string  `json:"product_id"`
+	Name       string  `json:"name"`
+	Score      float64 `json:"score"`
+	Avg        float64 `json:"avg"`
+	VotesCount int     `json:"votes_count"`
+}
+
+// RankProducts orders the products of the catalog by their score, so a product with few votes
+// doesn't outrank one with many slightly lower votes.
+// bayesian scores are on the 1-10 scale of the rates, wilson scores are the lower bound of the
+// normalized rate on a 0-1 scale
+func RankProducts(products map[string]*product.Product, avgs map[string]*ProductVote, opts RankingOptions) []*RankedProduct {
+	if opts.PriorWeight == 0 {
+		opts.PriorWeight = DefaultPriorWeight
+	}
+	if opts.PriorMean == 0 {
+		opts.PriorMean = globalMean(avgs)
+	}
+
+	ranked := make([]*RankedProduct, 0, len(products))
+	for id, pr := range products {
+		rp := &RankedProduct{ProductID: id, Name: pr.Name}
+		if pv, ok := avgs[id]; ok {
+			rp.Avg = pv.Avg
+			rp.VotesCount = pv.VotesCount
+		}
+
+		if opts.Method == RankingWilson {
+			rp.Score = wilsonLowerBound(rp.Avg, rp.VotesCount)
+		} else {
+			rp.Score = bayesianAverage(rp.Avg, rp.VotesCount, opts.PriorMean, opts.PriorWeight)
+		}
+		ranked = append(ranked, rp)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].VotesCount != ranked[j].VotesCount {
+			return ranked[i].VotesCount > ranked[j].VotesCount
+		}
+		return ranked[i].ProductID < ranked[j].ProductID
+	})
+	for i, rp := range ranked {
+		rp.Rank = i + 1
+	}
+	return ranked
+}
+
+// globalMean is the mean of all votes of all products, the middle of the scale if there are no votes
+func globalMean(avgs map[string]*ProductVote) float64 {
+	sum, count := 0.0, 0
+	for _, pv := range avgs {
+		sum += pv.Avg * float64(pv.VotesCount)
+		count += pv.VotesCount
+	}
+	if count == 0 {
+		return 5.5
+	}
+	return sum / float64(count)
+}
+
+// bayesianAverage pulls the avg towards the prior mean, the fewer votes the stronger the pull
+func bayesianAverage(avg float64, count int, priorMean, priorWeight float64) float64 {
+	n := float64(count)
+	return (priorWeight*priorMean + avg*n) / (priorWeight + n)
+}
+
+// wilsonLowerBound is the lower bound of the wilson score interval of the avg normalized to 0-1
+func wilsonLowerBound(avg float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	n := float64(count)
+	p := (avg - 1) / 9
+	z2 := wilsonZ * wilsonZ
+
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt(p*(1-p)/n+z2/(4*n*n))) / (1 + z2/n)
+}
@@ -0,0 +1,69 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankProducts(t *testing.T) {
+	products := map[string]*product.Product{
+		"few":  {ID: "few", Name: "One perfect vote"},
+		"many": {ID: "many", Name: "Many good votes"},
+		"none": {ID: "none", Name: "No votes"},
+	}
+	avgs := map[string]*ProductVote{
+		"few":  {Avg: 10, VotesCount: 1},
+		"many": {Avg: 9.2, VotesCount: 500},
+	}
+
+	for _, method := range []string{RankingBayesian, RankingWilson} {
+		ranked := RankProducts(products, avgs, RankingOptions{Method: method, PriorMean: 5.5})
+
+		assert.Len(t, ranked, 3, method)
+		assert.Equal(t, "many", ranked[0].ProductID, method)
+		assert.Equal(t, 1, ranked[0].Rank, method)
+		assert.Equal(t, "few", ranked[1].ProductID, method)
+		assert.Equal(t, "none", ranked[2].ProductID, method)
+	}
+
+	// Test case: the prior mean of a product with no votes is its score
+	ranked := RankProducts(products, avgs, RankingOptions{PriorMean: 7, PriorWeight: 5})
+	assert.Equal(t, 7.0, ranked[2].Score)
+}
+
+func TestRankingOptionsFromEnv(t *testing.T) {
+	t.Setenv("RANKING_METHOD", "")
+	t.Setenv("RANKING_PRIOR_MEAN", "")
+	t.Setenv("RANKING_PRIOR_WEIGHT", "")
+	opts, err := RankingOptionsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, RankingOptions{}, opts)
+
+	t.Setenv("RANKING_METHOD", "wilson")
+	t.Setenv("RANKING_PRIOR_MEAN", "7.5")
+	t.Setenv("RANKING_PRIOR_WEIGHT", "0")
+	opts, err = RankingOptionsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, RankingOptions{Method: RankingWilson, PriorMean: 7.5}, opts)
+
+	// Test case: invalid options fail
+	for env, value := range map[string]string{
+		"RANKING_METHOD":       "bayes",
+		"RANKING_PRIOR_MEAN":   "11",
+		"RANKING_PRIOR_WEIGHT": "-3",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			_, err := RankingOptionsFromEnv()
+			assert.Error(t, err)
+		})
+	}
+	for _, value := range []string{"NaN", "Inf", "ten"} {
+		t.Setenv("RANKING_PRIOR_WEIGHT", value)
+		_, err = RankingOptionsFromEnv()
+		assert.Error(t, err, value)
+	}
+}
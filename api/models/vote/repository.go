@@ -1,132 +1,535 @@
 package vote
 
 import (
+	"api_assignment/api/database"
 	"api_assignment/api/models/product"
 	"context"
+	"errors"
+	"strconv"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // AllVotes fetched all votes from the db
-func (vModel VoteModel) AllVotes() ([]*VoteResult, error) {
-	coll := vModel.DB.Database("trial").Collection("votes")
+func (vModel VoteModel) AllVotes(ctx context.Context) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
 
-	cur, err := coll.Find(context.TODO(), bson.D{})
+	coll := vModel.collection("votes")
+
+	cur, err := coll.Find(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
 	allVotes := make([]*VoteResult, 0)
-	if err := cur.All(context.TODO(), &allVotes); err != nil {
+	if err := cur.All(ctx, &allVotes); err != nil {
 		return nil, err
 	}
 	return allVotes, nil
 
 }
 
-// PostVote handles the repo side of the posting/updating of a vote
-func (vModel VoteModel) PostVote(newVote *VoteResult) (*bool, error) {
+// EachVote streams the votes from the cursor of the collection, decoding one document at a time.
+// The cursor follows the context, so a cancelled request stops fetching the next batches
+func (vModel VoteModel) EachVote(ctx context.Context, fn func(*VoteResult) error) error {
+	coll := vModel.collection("votes")
 
-	coll := vModel.DB.Database("trial").Collection("votes")
+	cur, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	// the request context may be done already, the cursor still has to be killed on the server
+	defer cur.Close(context.Background())
 
-	// create the search filter
-	filter := bson.D{{Key: "product_id", Value: newVote.ProductID}, {Key: "session_id", Value: newVote.SessionID}}
-	// update fields
-	update := bson.D{{Key: "$set", Value: bson.D{{Key: "product_id", Value: newVote.ProductID},
-		{Key: "session_id", Value: newVote.SessionID}, {Key: "rate", Value: newVote.Rate}}}}
-	// upsert; insert or update if exists
-	opts := options.Update().SetUpsert(true)
+	for cur.Next(ctx) {
+		v := &VoteResult{}
+		if err := cur.Decode(v); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
 
-	result, err := coll.UpdateOne(context.TODO(), filter, update, opts)
+// PostVote handles the repo side of the posting/updating of a vote.
+// The vote is upserted with findAndModify so the previous rate is known, which is then used to
+// update the aggregate of the product and to append the change to the vote_history collection.
+// The three writes run in a transaction, so the aggregate can't drift from the votes on a failure in between
+func (vModel VoteModel) PostVote(ctx context.Context, newVote *VoteResult) (*bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	var alreadyExist bool
+	post := func(sc mongo.SessionContext) (interface{}, error) {
+		coll := vModel.collection("votes")
+		now := time.Now().UTC()
+
+		// create the search filter
+		filter := bson.D{{Key: "product_id", Value: newVote.ProductID}, {Key: "session_id", Value: newVote.SessionID}}
+		// update fields
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "product_id", Value: newVote.ProductID},
+			{Key: "session_id", Value: newVote.SessionID}, {Key: "rate", Value: newVote.Rate}, {Key: "updated_at", Value: now},
+			{Key: "suspicious", Value: newVote.Suspicious}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: now}}}}
+		// upsert; insert or update if exists, and return the vote as it was before the update
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+		oldVote := &VoteResult{}
+		err := coll.FindOneAndUpdate(sc, filter, update, opts).Decode(oldVote)
+		alreadyExist = true
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// nothing matched; completely new
+			alreadyExist = false
+			oldVote = nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := vModel.updateAggregate(sc, newVote.ProductID, oldVote.counted(), newVote.counted()); err != nil {
+			return nil, err
+		}
+		if change := newVoteChange(oldVote, newVote, now); change != nil {
+			if _, err := vModel.collection("vote_history").InsertOne(sc, change); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	err := vModel.inTransaction(ctx, post)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent request inserted the same vote in between and the unique index rejected
+		// this insert, update the vote it inserted instead
+		err = vModel.inTransaction(ctx, post)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &alreadyExist, nil
 
-	// nothing matched; completely new
-	if result.MatchedCount == 0 {
-		alreadyExist := false
-		return &alreadyExist, nil
-	} else {
-		alreadyExist := true
-		return &alreadyExist, nil
+}
+
+// DeleteVote removes the vote with findAndModify, so the removed rate is known to update the
+// aggregate of the product, and appends the deletion to the vote_history collection, in a transaction like PostVote
+func (vModel VoteModel) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	return vModel.inTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		coll := vModel.collection("votes")
+
+		filter := bson.D{{Key: "product_id", Value: productID}, {Key: "session_id", Value: sessionID}}
+		oldVote := &VoteResult{}
+		err := coll.FindOneAndDelete(sc, filter).Decode(oldVote)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := vModel.updateAggregate(sc, productID, oldVote.counted(), nil); err != nil {
+			return nil, err
+		}
+		_, err = vModel.collection("vote_history").InsertOne(sc, newVoteChange(oldVote, nil, time.Now().UTC()))
+		return nil, err
+	})
+}
+
+// inTransaction runs fn in a transaction of a new session. The driver retries fn on the transient
+// errors, like a write conflict with a concurrent vote. Transactions need a replica set, which atlas provides
+func (vModel VoteModel) inTransaction(ctx context.Context, fn func(mongo.SessionContext) (interface{}, error)) error {
+	return vModel.DB.UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, fn)
+		return err
+	})
+}
+
+// GetVoteHistory returns the changes of the votes of the product from the vote_history collection
+func (vModel VoteModel) GetVoteHistory(ctx context.Context, productID string) ([]*VoteChange, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	coll := vModel.collection("vote_history")
+
+	filter := bson.D{{Key: "product_id", Value: productID}}
+	opts := options.Find().SetSort(bson.D{{Key: "changed_at", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*VoteChange, 0)
+	if err := cur.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetProductTrend buckets the votes of the product with an aggregation, grouping them by the time
+// they were first cast truncated to the bucket. Votes cast before the times were saved and suspicious votes are left out
+func (vModel VoteModel) GetProductTrend(ctx context.Context, productID string, opts TrendOptions) (*ProductTrend, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	castRange := bson.D{{Key: "$type", Value: "date"}}
+	if !opts.From.IsZero() {
+		castRange = append(castRange, bson.E{Key: "$gte", Value: opts.From})
+	}
+	if !opts.To.IsZero() {
+		castRange = append(castRange, bson.E{Key: "$lt", Value: opts.To})
+	}
+	dateTrunc := bson.D{{Key: "date", Value: "$cast"}, {Key: "unit", Value: opts.Bucket}}
+	if opts.Bucket == BucketWeek {
+		dateTrunc = append(dateTrunc, bson.E{Key: "startOfWeek", Value: "monday"})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "product_id", Value: productID}, {Key: "suspicious", Value: bson.D{{Key: "$ne", Value: true}}}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "rate", Value: 1},
+			{Key: "cast", Value: "$created_at"},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "cast", Value: castRange}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$dateTrunc", Value: dateTrunc}}},
+			{Key: "avg", Value: bson.D{{Key: "$avg", Value: "$rate"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
 	}
 
+	cur, err := vModel.collection("votes").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	trend := &ProductTrend{ProductID: productID, Bucket: opts.Bucket, Buckets: make([]*TrendBucket, 0)}
+	if err := cur.All(ctx, &trend.Buckets); err != nil {
+		return nil, err
+	}
+	for _, bucket := range trend.Buckets {
+		bucket.Start = bucket.Start.UTC()
+	}
+	return trend, nil
 }
 
 // GetVotesBySessionID handles the db side of returning all votes with the specified session id
-func (vModel VoteModel) GetVotesBySessionID(sessionID string) ([]*VoteResult, error) {
+func (vModel VoteModel) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
 
-	coll := vModel.DB.Database("trial").Collection("votes")
+	coll := vModel.collection("votes")
 
 	filter := bson.D{{Key: "session_id", Value: sessionID}}
 
 	var foundVotes []*VoteResult
-	cur, err := coll.Find(context.TODO(), filter)
+	cur, err := coll.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	cur.All(context.TODO(), &foundVotes)
+	if err := cur.All(ctx, &foundVotes); err != nil {
+		return nil, err
+	}
 
 	return foundVotes, nil
 
 }
 
 // GetVotesByProductID fetches all votes by the corresponding product id
-func (vModel VoteModel) GetVotesByProductID(productID string) ([]*VoteResult, error) {
+func (vModel VoteModel) GetVotesByProductID(ctx context.Context, productID string) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
 
-	coll := vModel.DB.Database("trial").Collection("votes")
+	coll := vModel.collection("votes")
 
 	filter := bson.D{{Key: "product_id", Value: productID}}
 
 	var foundVotes []*VoteResult
-	cur, err := coll.Find(context.TODO(), filter)
+	cur, err := coll.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-
-	cur.All(context.TODO(), &foundVotes)
+	if err := cur.All(ctx, &foundVotes); err != nil {
+		return nil, err
+	}
 
 	return foundVotes, nil
 
 }
 
-// GetAvergageVotesForAllProducts handles the actual logic of fetching votes and calculating avgs.
-func (vModel VoteModel) GetAverageVotesForAllProducts(products map[string]*product.Product) (map[string]*ProductVote, error) {
+// ListVotes lets mongo filter, sort and paginate the votes.
+// Sorting by time uses the _id, which grows with the insertion time and exists for the votes
+// cast before created_at did. The page starts right after the sort values of the cursor
+func (vModel VoteModel) ListVotes(ctx context.Context, opts ListOptions) (*Page, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	after, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
 
-	coll := vModel.DB.Database("trial").Collection("votes")
+	coll := vModel.collection("votes")
 
-	// fetch everything
-	filter := bson.D{{}}
+	filter := bson.D{}
+	if opts.ProductID != "" {
+		filter = append(filter, bson.E{Key: "product_id", Value: opts.ProductID})
+	}
+	if opts.SessionID != "" {
+		filter = append(filter, bson.E{Key: "session_id", Value: opts.SessionID})
+	}
+	rateRange := bson.D{}
+	if opts.MinRate > 0 {
+		rateRange = append(rateRange, bson.E{Key: "$gte", Value: opts.MinRate})
+	}
+	if opts.MaxRate > 0 {
+		rateRange = append(rateRange, bson.E{Key: "$lte", Value: opts.MaxRate})
+	}
+	if len(rateRange) > 0 {
+		filter = append(filter, bson.E{Key: "rate", Value: rateRange})
+	}
 
-	var foundVotes []*VoteResult
-	cur, err := coll.Find(context.TODO(), filter)
+	order, cmp := 1, "$gt"
+	if opts.Desc {
+		order, cmp = -1, "$lt"
+	}
+
+	var sortBy bson.D
+	if opts.Sort == SortRate {
+		sortBy = bson.D{{Key: "rate", Value: order}, {Key: "product_id", Value: order}, {Key: "session_id", Value: order}}
+	} else {
+		sortBy = bson.D{{Key: "_id", Value: order}}
+	}
+
+	if after != nil {
+		if opts.Sort == SortRate {
+			// (rate, product_id, session_id) after the ones of the cursor
+			filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+				bson.D{{Key: "rate", Value: bson.D{{Key: cmp, Value: after.Rate}}}},
+				bson.D{{Key: "rate", Value: after.Rate}, {Key: "product_id", Value: bson.D{{Key: cmp, Value: after.ProductID}}}},
+				bson.D{{Key: "rate", Value: after.Rate}, {Key: "product_id", Value: after.ProductID},
+					{Key: "session_id", Value: bson.D{{Key: cmp, Value: after.SessionID}}}},
+			}})
+		} else {
+			id, err := primitive.ObjectIDFromHex(after.ID)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: cmp, Value: id}}})
+		}
+	}
+
+	// one more than the limit, to know whether there is a next page
+	findOpts := options.Find().SetSort(sortBy).SetLimit(int64(opts.Limit) + 1)
+	cur, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID         primitive.ObjectID `bson:"_id"`
+		VoteResult `bson:",inline"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	votes := make([]*VoteResult, len(docs))
+	ids := make([]string, len(docs))
+	for i := range docs {
+		votes[i] = &docs[i].VoteResult
+		ids[i] = docs[i].ID.Hex()
+	}
+	return newPage(votes, &opts, ids), nil
+}
+
+// GetAverageVotesForAllProducts reads the aggregates maintained by PostVote, one document per product
+func (vModel VoteModel) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*ProductVote, error) {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	coll := vModel.collection("aggregates")
+
+	cur, err := coll.Find(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
-	cur.All(context.TODO(), &foundVotes)
+	var docs []*aggregateDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	avgVotes := make(map[string]*ProductVote, len(docs))
+	for _, doc := range docs {
+		avgVotes[doc.ProductID] = doc.aggregate().ProductVote()
+	}
+
+	fillMissingProducts(avgVotes, products)
+	return avgVotes, nil
+}
+
+// aggregateDoc is how an Aggregate is saved in mongo. The histogram is a sub document keyed
+// by the rate instead of an array, so a single upsert with $inc can create it
+type aggregateDoc struct {
+	ProductID string         `bson:"product_id"`
+	Sum       int            `bson:"sum"`
+	Count     int            `bson:"count"`
+	Histogram map[string]int `bson:"histogram"`
+}
+
+func (doc *aggregateDoc) aggregate() *Aggregate {
+	agg := &Aggregate{ProductID: doc.ProductID, Sum: doc.Sum, Count: doc.Count}
+	for rate := 1; rate <= len(agg.Histogram); rate++ {
+		agg.Histogram[rate-1] = doc.Histogram[strconv.Itoa(rate)]
+	}
+	return agg
+}
+
+// indexOptionsConflict is the code of the mongo error for an index existing with other options
+const indexOptionsConflict = 85
+
+// EnsureIndexes creates the indexes the model relies on.
+// The unique indexes on the aggregates and on the votes of a session for a product make concurrent upserts
+// of the same document fail instead of inserting it twice; the others back the lookups and the listings
+// of the votes and their history
+func (vModel VoteModel) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx, vModel.Timeout)
+	defer cancel()
+
+	_, err := vModel.collection("aggregates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
 
-	// fill data from the quesry result
-	avgVotes := make(map[string]*ProductVote)
-	for _, vote := range foundVotes {
-		if _, ok := avgVotes[vote.ProductID]; !ok {
-			avgVotes[vote.ProductID] = &ProductVote{}
+	votes := vModel.collection("votes").Indexes()
+	unique := mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "session_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = votes.CreateOne(ctx, unique)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == indexOptionsConflict {
+		// the index was created without the uniqueness by an earlier version, it is replaced
+		if _, err = votes.DropOne(ctx, "product_id_1_session_id_1"); err == nil {
+			_, err = votes.CreateOne(ctx, unique)
 		}
-		avgVotes[vote.ProductID].sum += vote.Rate
-		avgVotes[vote.ProductID].VotesCount++
+	}
+	if err != nil {
+		return err
 	}
 
-	// calculate the avg
-	for prodID := range avgVotes {
-		avgVotes[prodID].Avg = float64(avgVotes[prodID].sum) / float64(avgVotes[prodID].VotesCount)
+	_, err = votes.CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "session_id", Value: 1}}},
+		{Keys: bson.D{{Key: "rate", Value: 1}, {Key: "product_id", Value: 1}, {Key: "session_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
 	}
 
-	// fill products with no votes
-	for prodID := range products {
-		if _, ok := avgVotes[prodID]; !ok {
-			avgVotes[prodID] = &ProductVote{sum: 0, Avg: 0.0, VotesCount: 0}
+	_, err = vModel.collection("vote_history").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "changed_at", Value: 1}},
+	})
+	return err
+}
+
+// updateAggregate applies the change of a vote from oldVote to newVote to the aggregate of the product.
+// Either vote can be nil, for a newly inserted, a removed or a suspicious vote. The change is a single $inc so
+// concurrent votes can't overwrite each other
+func (vModel VoteModel) updateAggregate(ctx context.Context, productID string, oldVote, newVote *VoteResult) error {
+
+	inc := make(map[string]int)
+	if oldVote != nil {
+		inc["sum"] -= oldVote.Rate
+		inc["count"]--
+		inc["histogram."+strconv.Itoa(oldVote.Rate)]--
+	}
+	if newVote != nil {
+		inc["sum"] += newVote.Rate
+		inc["count"]++
+		inc["histogram."+strconv.Itoa(newVote.Rate)]++
+	}
 
+	// e.g. the same rate was posted again
+	update := bson.M{}
+	for field, n := range inc {
+		if n != 0 {
+			update[field] = n
 		}
 	}
+	if len(update) == 0 {
+		return nil
+	}
 
-	return avgVotes, nil
+	coll := vModel.collection("aggregates")
+	filter := bson.D{{Key: "product_id", Value: productID}}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := coll.UpdateOne(ctx, filter, bson.D{{Key: "$inc", Value: update}}, opts)
+	return err
+}
+
+// ReconcileAggregates rebuilds the aggregates from the raw votes.
+// mongo groups the votes by product and rate, so only the histogram buckets leave the db.
+// Votes posted while reconciling may be missed, so it is best run while the traffic is low
+func (vModel VoteModel) ReconcileAggregates(ctx context.Context) error {
+
+	pipeline := mongo.Pipeline{
+		// the suspicious votes don't count, like in updateAggregate
+		{{Key: "$match", Value: bson.D{{Key: "suspicious", Value: bson.D{{Key: "$ne", Value: true}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "product_id", Value: "$product_id"}, {Key: "rate", Value: "$rate"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+
+	cur, err := vModel.collection("votes").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var buckets []struct {
+		ID struct {
+			ProductID string `bson:"product_id"`
+			Rate      int    `bson:"rate"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cur.All(ctx, &buckets); err != nil {
+		return err
+	}
+
+	docs := make(map[string]*aggregateDoc)
+	for _, bucket := range buckets {
+		doc, ok := docs[bucket.ID.ProductID]
+		if !ok {
+			doc = &aggregateDoc{ProductID: bucket.ID.ProductID, Histogram: make(map[string]int)}
+			docs[bucket.ID.ProductID] = doc
+		}
+		doc.Sum += bucket.ID.Rate * bucket.Count
+		doc.Count += bucket.Count
+		doc.Histogram[strconv.Itoa(bucket.ID.Rate)] += bucket.Count
+	}
+
+	coll := vModel.collection("aggregates")
+	productIDs := make([]string, 0, len(docs))
+	for productID, doc := range docs {
+		productIDs = append(productIDs, productID)
+		filter := bson.D{{Key: "product_id", Value: productID}}
+		if _, err := coll.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true)); err != nil {
+			return err
+		}
+	}
+
+	// drop the aggregates of products that have no votes anymore
+	_, err = coll.DeleteMany(ctx, bson.D{{Key: "product_id", Value: bson.D{{Key: "$nin", Value: productIDs}}}})
+	return err
 }
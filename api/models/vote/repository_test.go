@@ -0,0 +1,102 @@
+package vote
+
+import (
+	"api_assignment/api/models/product"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	benchProducts        = 20
+	benchVotesPerProduct = 5000
+)
+
+// newBenchVoteModel connects to the mongo at MONGO_TEST_URI and seeds a throwaway db with votes and their aggregates.
+// The benchmark is skipped when no mongo is configured
+func newBenchVoteModel(b *testing.B) (VoteModel, map[string]*product.Product) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		b.Skip("MONGO_TEST_URI is not set")
+	}
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	if err != nil {
+		b.Fatal(err)
+	}
+	vModel := VoteModel{DB: client, DBName: "votes_benchmark"}
+	b.Cleanup(func() {
+		client.Database(vModel.DBName).Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+
+	coll := vModel.collection("votes")
+	if err := coll.Drop(context.TODO()); err != nil {
+		b.Fatal(err)
+	}
+
+	products := make(map[string]*product.Product, benchProducts)
+	docs := make([]interface{}, 0, benchProducts*benchVotesPerProduct)
+	for p := 0; p < benchProducts; p++ {
+		productID := fmt.Sprint(p)
+		products[productID] = &product.Product{ID: productID}
+		for s := 0; s < benchVotesPerProduct; s++ {
+			docs = append(docs, &VoteResult{ProductID: productID, SessionID: fmt.Sprint(s), Rate: s%10 + 1})
+		}
+	}
+	if _, err := coll.InsertMany(context.TODO(), docs); err != nil {
+		b.Fatal(err)
+	}
+	if err := vModel.ReconcileAggregates(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	return vModel, products
+}
+
+// BenchmarkAveragesFindAll measures the previous approach; every vote is fetched and summed in Go
+func BenchmarkAveragesFindAll(b *testing.B) {
+	vModel, products := newBenchVoteModel(b)
+	coll := vModel.collection("votes")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var foundVotes []*VoteResult
+		cur, err := coll.Find(context.TODO(), bson.D{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := cur.All(context.TODO(), &foundVotes); err != nil {
+			b.Fatal(err)
+		}
+		averageVotes(foundVotes, products)
+	}
+}
+
+// BenchmarkAveragesAggregates measures reading the aggregates maintained by VoteModel
+func BenchmarkAveragesAggregates(b *testing.B) {
+	vModel, products := newBenchVoteModel(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vModel.GetAverageVotesForAllProducts(context.Background(), products); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReconcileAggregates measures rebuilding the aggregates with the $group pipeline
+func BenchmarkReconcileAggregates(b *testing.B) {
+	vModel, _ := newBenchVoteModel(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := vModel.ReconcileAggregates(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
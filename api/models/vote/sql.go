@@ -0,0 +1,380 @@
+package vote
+
+import (
+	"api_assignment/api/database"
+	"api_assignment/api/models/product"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore is the database/sql implementation of Store, it works with both sqlite and postgres
+type SQLStore struct {
+	DB *database.DB
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero.
+	// EachVote bounds each page of votes it reads, exports may take longer
+	Timeout time.Duration
+}
+
+// AllVotes fetches all votes from the db
+func (s SQLStore) AllVotes(ctx context.Context) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes`)
+}
+
+// voteColumns are the columns selected for a vote, in the order scanVote reads them
+const voteColumns = "product_id, session_id, rate, created_at, updated_at, suspicious"
+
+// PostVote inserts the vote, or updates its rate if the session already voted for the product,
+// and appends the change to vote_history in the same transaction.
+// The previous rate is read first, locking the row on postgres so concurrent updates of the
+// vote record their changes one after the other
+func (s SQLStore) PostVote(ctx context.Context, newVote *VoteResult) (*bool, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	oldVote, err := s.lockVote(ctx, tx, newVote.ProductID, newVote.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldVote == nil {
+		result, err := tx.ExecContext(ctx, s.DB.Rebind(`INSERT INTO votes (product_id, session_id, rate, created_at, updated_at, suspicious) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (product_id, session_id) DO NOTHING`),
+			newVote.ProductID, newVote.SessionID, newVote.Rate, now, now, newVote.Suspicious)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted == 0 {
+			// a concurrent request inserted the same vote in between, update it instead
+			if oldVote, err = s.lockVote(ctx, tx, newVote.ProductID, newVote.SessionID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	alreadyExist := oldVote != nil
+	if alreadyExist {
+		_, err := tx.ExecContext(ctx, s.DB.Rebind(`UPDATE votes SET rate = ?, updated_at = ?, suspicious = ? WHERE product_id = ? AND session_id = ?`),
+			newVote.Rate, now, newVote.Suspicious, newVote.ProductID, newVote.SessionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.insertChange(ctx, tx, newVoteChange(oldVote, newVote, now)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &alreadyExist, nil
+}
+
+// DeleteVote removes the vote and appends the deletion to vote_history in the same transaction
+func (s SQLStore) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldVote, err := s.lockVote(ctx, tx, productID, sessionID)
+	if err != nil {
+		return err
+	}
+	if oldVote == nil {
+		return ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, s.DB.Rebind(`DELETE FROM votes WHERE product_id = ? AND session_id = ?`), productID, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := s.insertChange(ctx, tx, newVoteChange(oldVote, nil, time.Now().UTC())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertChange appends the change to vote_history, nil changes are skipped
+func (s SQLStore) insertChange(ctx context.Context, tx *sql.Tx, change *VoteChange) error {
+	if change == nil {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, s.DB.Rebind(`INSERT INTO vote_history (product_id, session_id, old_rate, new_rate, deleted, changed_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		change.ProductID, change.SessionID, change.OldRate, change.NewRate, change.Deleted, change.ChangedAt)
+	return err
+}
+
+// lockVote reads the vote within the transaction, nil if there is none.
+// sqlite has no row locks, but it only runs a single write transaction at a time anyway
+func (s SQLStore) lockVote(ctx context.Context, tx *sql.Tx, productID, sessionID string) (*VoteResult, error) {
+	query := `SELECT ` + voteColumns + ` FROM votes WHERE product_id = ? AND session_id = ?`
+	if s.DB.Dialect == database.Postgres {
+		query += ` FOR UPDATE`
+	}
+
+	rows, err := tx.QueryContext(ctx, s.DB.Rebind(query), productID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	votes, err := scanVotes(rows)
+	if err != nil || len(votes) == 0 {
+		return nil, err
+	}
+	return votes[0], nil
+}
+
+// GetVoteHistory fetches the changes of the votes of the product from vote_history
+func (s SQLStore) GetVoteHistory(ctx context.Context, productID string) ([]*VoteChange, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, s.DB.Rebind(`SELECT product_id, session_id, old_rate, new_rate, deleted, changed_at FROM vote_history
+		WHERE product_id = ? ORDER BY changed_at, session_id`), productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]*VoteChange, 0)
+	for rows.Next() {
+		change := &VoteChange{}
+		var oldRate sql.NullInt64
+		if err := rows.Scan(&change.ProductID, &change.SessionID, &oldRate, &change.NewRate, &change.Deleted, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		if oldRate.Valid {
+			rate := int(oldRate.Int64)
+			change.OldRate = &rate
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// GetProductTrend fetches the votes of the product first cast within the bounds and buckets them in Go
+func (s SQLStore) GetProductTrend(ctx context.Context, productID string, opts TrendOptions) (*ProductTrend, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	query := `SELECT ` + voteColumns + ` FROM votes WHERE product_id = ?`
+	args := []any{productID}
+	if !opts.From.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, opts.From.UTC())
+	}
+	if !opts.To.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, opts.To.UTC())
+	}
+
+	votes, err := s.queryVotes(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return computeTrend(productID, votes, opts)
+}
+
+// GetVotesBySessionID fetches all votes with the specified session id
+func (s SQLStore) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE session_id = ?`, sessionID)
+}
+
+// GetVotesByProductID fetches all votes with the specified product id
+func (s SQLStore) GetVotesByProductID(ctx context.Context, productID string) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE product_id = ?`, productID)
+}
+
+// GetAverageVotesForAllProducts lets the db aggregate the votes of each product
+func (s SQLStore) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*ProductVote, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT product_id, SUM(rate), COUNT(*), MIN(rate), MAX(rate) FROM votes
+		WHERE NOT suspicious GROUP BY product_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	avgVotes := make(map[string]*ProductVote)
+	for rows.Next() {
+		var productID string
+		pv := &ProductVote{}
+		if err := rows.Scan(&productID, &pv.Sum, &pv.VotesCount, &pv.Min, &pv.Max); err != nil {
+			return nil, err
+		}
+		pv.Avg = float64(pv.Sum) / float64(pv.VotesCount)
+		avgVotes[productID] = pv
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fillMissingProducts(avgVotes, products)
+	return avgVotes, nil
+}
+
+// ListVotes lets the db filter, sort and paginate the votes.
+// The page starts right after the sort values of the cursor, compared as a row value
+func (s SQLStore) ListVotes(ctx context.Context, opts ListOptions) (*Page, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	after, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"1 = 1"}
+	args := []any{}
+	if opts.ProductID != "" {
+		where = append(where, "product_id = ?")
+		args = append(args, opts.ProductID)
+	}
+	if opts.SessionID != "" {
+		where = append(where, "session_id = ?")
+		args = append(args, opts.SessionID)
+	}
+	if opts.MinRate > 0 {
+		where = append(where, "rate >= ?")
+		args = append(args, opts.MinRate)
+	}
+	if opts.MaxRate > 0 {
+		where = append(where, "rate <= ?")
+		args = append(args, opts.MaxRate)
+	}
+
+	sortColumn := "created_at"
+	if opts.Sort == SortRate {
+		sortColumn = "rate"
+	}
+	order, cmp := "ASC", ">"
+	if opts.Desc {
+		order, cmp = "DESC", "<"
+	}
+
+	if after != nil {
+		where = append(where, fmt.Sprintf("(%s, product_id, session_id) %s (?, ?, ?)", sortColumn, cmp))
+		if opts.Sort == SortRate {
+			args = append(args, after.Rate)
+		} else {
+			args = append(args, after.Time)
+		}
+		args = append(args, after.ProductID, after.SessionID)
+	}
+
+	// one more than the limit, to know whether there is a next page
+	query := fmt.Sprintf(`SELECT `+voteColumns+` FROM votes WHERE %s
+		ORDER BY %[2]s %[3]s, product_id %[3]s, session_id %[3]s LIMIT ?`, strings.Join(where, " AND "), sortColumn, order)
+	args = append(args, opts.Limit+1)
+
+	votes, err := s.queryVotes(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(votes, &opts, nil), nil
+}
+
+// exportPageSize is the number of votes EachVote reads at once
+var exportPageSize = 500
+
+// EachVote reads the votes in pages, ordered by product and session. The connection is released
+// between the pages: sqlite has a single one, which an export streaming to a slow client would
+// otherwise hold, blocking every other request
+func (s SQLStore) EachVote(ctx context.Context, fn func(*VoteResult) error) error {
+	var after *VoteResult
+	for {
+		votes, err := s.votesAfter(ctx, after)
+		if err != nil {
+			return err
+		}
+		for _, v := range votes {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		if len(votes) < exportPageSize {
+			return nil
+		}
+		after = votes[len(votes)-1]
+	}
+}
+
+// votesAfter returns the page of votes following the vote, from the first one when it is nil
+func (s SQLStore) votesAfter(ctx context.Context, after *VoteResult) ([]*VoteResult, error) {
+	ctx, cancel := database.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	if after == nil {
+		return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes ORDER BY product_id, session_id LIMIT ?`, exportPageSize)
+	}
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE (product_id, session_id) > (?, ?)
+		ORDER BY product_id, session_id LIMIT ?`, after.ProductID, after.SessionID, exportPageSize)
+}
+
+// queryVotes runs a query selecting the voteColumns and scans the votes
+func (s SQLStore) queryVotes(ctx context.Context, query string, args ...any) ([]*VoteResult, error) {
+	rows, err := s.DB.QueryContext(ctx, s.DB.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanVotes(rows)
+}
+
+func scanVotes(rows *sql.Rows) ([]*VoteResult, error) {
+	defer rows.Close()
+
+	foundVotes := make([]*VoteResult, 0)
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, err
+		}
+		foundVotes = append(foundVotes, v)
+	}
+	return foundVotes, rows.Err()
+}
+
+// scanVote scans the vote of the current row, selected as voteColumns
+func scanVote(rows *sql.Rows) (*VoteResult, error) {
+	v := &VoteResult{}
+	var createdAt, updatedAt sql.NullTime
+	if err := rows.Scan(&v.ProductID, &v.SessionID, &v.Rate, &createdAt, &updatedAt, &v.Suspicious); err != nil {
+		return nil, err
+	}
+	if createdAt.Valid {
+		v.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		v.UpdatedAt = &updatedAt.Time
+	}
+	return v, nil
+}
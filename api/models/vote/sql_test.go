@@ -0,0 +1,142 @@
+package vote
+
+import (
+	"api_assignment/api/database"
+	"api_assignment/api/models/product"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLStore(t *testing.T) SQLStore {
+	db, err := database.Open(database.SQLite, ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return SQLStore{DB: db}
+}
+
+func TestSQLStorePostVote(t *testing.T) {
+	store := newTestSQLStore(t)
+
+	// Test case: new vote
+	exists, err := store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 4})
+	assert.NoError(t, err)
+	assert.False(t, *exists)
+
+	// Test case: same session and product updates the rate
+	exists, err = store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 9})
+	assert.NoError(t, err)
+	assert.True(t, *exists)
+
+	votes, err := store.GetVotesBySessionID(context.Background(), "s1")
+	assert.NoError(t, err)
+	// the creation time is kept by the update, the update time moves on
+	require.Len(t, votes, 1)
+	require.NotNil(t, votes[0].CreatedAt)
+	require.NotNil(t, votes[0].UpdatedAt)
+	assert.False(t, votes[0].UpdatedAt.Before(*votes[0].CreatedAt))
+	assert.Equal(t, []*VoteResult{{ProductID: "p1", SessionID: "s1", Rate: 9, CreatedAt: votes[0].CreatedAt, UpdatedAt: votes[0].UpdatedAt}}, votes)
+}
+
+func TestSQLStoreCancelled(t *testing.T) {
+	store := newTestSQLStore(t)
+
+	// Test case: the request is gone, the vote is not written
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := store.PostVote(ctx, &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 4})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	votes, err := store.AllVotes(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, votes)
+}
+
+func TestSQLStoreAverages(t *testing.T) {
+	store := newTestSQLStore(t)
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s2", Rate: 9})
+	// suspicious votes are left out of the averages
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s3", Rate: 1, Suspicious: true})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p2", SessionID: "s3", Rate: 1, Suspicious: true})
+
+	avgs, err := store.GetAverageVotesForAllProducts(context.Background(), map[string]*product.Product{
+		"p1": {ID: "p1"},
+		"p2": {ID: "p2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7.5, avgs["p1"].Avg)
+	assert.Equal(t, 2, avgs["p1"].VotesCount)
+	assert.Equal(t, 0, avgs["p2"].VotesCount)
+}
+
+func TestSQLStoreEachVote(t *testing.T) {
+	store := newTestSQLStore(t)
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: "s1", Rate: 6})
+	store.PostVote(context.Background(), &VoteResult{ProductID: "p2", SessionID: "s1", Rate: 9})
+
+	count := 0
+	err := store.EachVote(context.Background(), func(v *VoteResult) error {
+		count++
+		assert.NotNil(t, v.CreatedAt)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Test case: the error of the callback stops the iteration
+	stop := errors.New("stop")
+	err = store.EachVote(context.Background(), func(v *VoteResult) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestSQLStoreEachVotePages(t *testing.T) {
+	defer func(size int) { exportPageSize = size }(exportPageSize)
+	exportPageSize = 2
+
+	store := newTestSQLStore(t)
+	for _, id := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		_, err := store.PostVote(context.Background(), &VoteResult{ProductID: "p1", SessionID: id, Rate: 5})
+		require.NoError(t, err)
+	}
+
+	// Test case: every vote is read once, across the pages
+	sessions := []string{}
+	err := store.EachVote(context.Background(), func(v *VoteResult) error {
+		sessions = append(sessions, v.SessionID)
+		// the connection is free while the votes of a page are handled, e.g. sent to the client
+		_, err := store.GetVotesBySessionID(context.Background(), v.SessionID)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s1", "s2", "s3", "s4", "s5"}, sessions)
+}
+
+func TestSQLStoreProductTrend(t *testing.T) {
+	store := newTestSQLStore(t)
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, rate := range []int{4, 8, 6} {
+		cast := day.Add(time.Duration(i) * 12 * time.Hour)
+		_, err := store.DB.Exec(`INSERT INTO votes (product_id, session_id, rate, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			"p1", fmt.Sprint("s", i), rate, cast, cast)
+		require.NoError(t, err)
+	}
+
+	// a vote changed days after it was first cast is bucketed by its first cast
+	_, err := store.DB.Exec(`INSERT INTO votes (product_id, session_id, rate, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		"p1", "changed", 10, day.Add(13*time.Hour), day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+
+	trend, err := store.GetProductTrend(context.Background(), "p1", TrendOptions{From: day.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, []*TrendBucket{
+		{Start: day, Avg: 9, Count: 2},
+		{Start: day.AddDate(0, 0, 1), Avg: 6, Count: 1},
+	}, trend.Buckets)
+}
@@ -0,0 +1,77 @@
+package vote
+
+import (
+	"math"
+	"sort"
+)
+
+// PositiveRate is the lowest rate that counts as a positive vote
+const PositiveRate = 6
+
+// ProductStats describes the distribution of the votes of a product
+type ProductStats struct {
+	ProductID  string `json:"product_id"`
+	VotesCount int    `json:"votes_count"`
+	// Histogram holds the number of votes of each rate; index 0 is rate 1 and index 9 is rate 10
+	Histogram [10]int `json:"histogram"`
+	Avg       float64 `json:"avg"`
+	Median    float64 `json:"median"`
+	StdDev    float64 `json:"std_dev"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	// PositivePercentage is the share of votes rated PositiveRate or higher, 0-100
+	PositivePercentage float64 `json:"positive_percentage"`
+}
+
+// ComputeStats calculates the distribution of the passed votes of a product.
+// Suspicious votes are left out, like in the avgs
+func ComputeStats(productID string, votes []*VoteResult) *ProductStats {
+	counted := make([]*VoteResult, 0, len(votes))
+	for _, v := range votes {
+		if v.counted() != nil {
+			counted = append(counted, v)
+		}
+	}
+	votes = counted
+
+	stats := &ProductStats{ProductID: productID, VotesCount: len(votes)}
+	if len(votes) == 0 {
+		return stats
+	}
+
+	rates := make([]int, 0, len(votes))
+	sum, positive := 0, 0
+	for _, vote := range votes {
+		rates = append(rates, vote.Rate)
+		sum += vote.Rate
+		if vote.Rate >= 1 && vote.Rate <= len(stats.Histogram) {
+			stats.Histogram[vote.Rate-1]++
+		}
+		if vote.Rate >= PositiveRate {
+			positive++
+		}
+	}
+	sort.Ints(rates)
+
+	n := float64(len(rates))
+	stats.Avg = float64(sum) / n
+	stats.Min = rates[0]
+	stats.Max = rates[len(rates)-1]
+	stats.PositivePercentage = float64(positive) / n * 100
+
+	mid := len(rates) / 2
+	if len(rates)%2 == 0 {
+		stats.Median = float64(rates[mid-1]+rates[mid]) / 2
+	} else {
+		stats.Median = float64(rates[mid])
+	}
+
+	// population standard deviation, every vote of the product is known
+	variance := 0.0
+	for _, rate := range rates {
+		variance += math.Pow(float64(rate)-stats.Avg, 2)
+	}
+	stats.StdDev = math.Sqrt(variance / n)
+
+	return stats
+}
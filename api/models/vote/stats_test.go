@@ -0,0 +1,33 @@
+package vote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	// Test case: no votes
+	stats := ComputeStats("p1", nil)
+	assert.Equal(t, &ProductStats{ProductID: "p1"}, stats)
+
+	// Test case: even number of votes
+	stats = ComputeStats("p1", []*VoteResult{{Rate: 2}, {Rate: 4}, {Rate: 4}, {Rate: 10}})
+	assert.Equal(t, 4, stats.VotesCount)
+	assert.Equal(t, [10]int{0, 1, 0, 2, 0, 0, 0, 0, 0, 1}, stats.Histogram)
+	assert.Equal(t, 5.0, stats.Avg)
+	assert.Equal(t, 4.0, stats.Median)
+	assert.InDelta(t, 3.0, stats.StdDev, 1e-9)
+	assert.Equal(t, 2, stats.Min)
+	assert.Equal(t, 10, stats.Max)
+	assert.Equal(t, 25.0, stats.PositivePercentage)
+
+	// Test case: odd number of votes
+	stats = ComputeStats("p1", []*VoteResult{{Rate: 9}, {Rate: 7}, {Rate: 1}})
+	assert.Equal(t, 7.0, stats.Median)
+
+	// Test case: suspicious votes are left out
+	stats = ComputeStats("p1", []*VoteResult{{Rate: 9}, {Rate: 1, Suspicious: true}})
+	assert.Equal(t, 1, stats.VotesCount)
+	assert.Equal(t, 9.0, stats.Avg)
+}
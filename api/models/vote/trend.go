@@ -0,0 +1,105 @@
+package vote
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// sizes of the trend buckets
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	// BucketWeek starts on monday, like ISO weeks
+	BucketWeek = "week"
+)
+
+// TrendOptions selects the votes of a trend and how they are bucketed
+type TrendOptions struct {
+	// Bucket is BucketHour, BucketDay (default) or BucketWeek
+	Bucket string
+	// From and To bound the time the votes were first cast to [From, To), a zero time means no bound
+	From time.Time
+	To   time.Time
+}
+
+// TrendBucket holds the votes first cast within a bucket of time, with their current rates
+type TrendBucket struct {
+	// Start is the beginning of the bucket in UTC
+	Start time.Time `json:"start" bson:"_id"`
+	Avg   float64   `json:"avg" bson:"avg"`
+	Count int       `json:"votes_count" bson:"count"`
+}
+
+// ProductTrend is the evolution of the votes of a product, buckets without votes are left out
+type ProductTrend struct {
+	ProductID string         `json:"product_id"`
+	Bucket    string         `json:"bucket"`
+	Buckets   []*TrendBucket `json:"buckets"`
+}
+
+// normalize fills the defaults of the options and checks them
+func (opts *TrendOptions) normalize() error {
+	if opts.Bucket == "" {
+		opts.Bucket = BucketDay
+	}
+	if opts.Bucket != BucketHour && opts.Bucket != BucketDay && opts.Bucket != BucketWeek {
+		return errors.New("bucket must be hour, day or week")
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// includes tells whether the cast time is within the bounds of the options
+func (opts *TrendOptions) includes(t time.Time) bool {
+	return (opts.From.IsZero() || !t.Before(opts.From)) && (opts.To.IsZero() || t.Before(opts.To))
+}
+
+// bucketStart returns the start of the bucket holding t
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// days since monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// computeTrend buckets the votes of the product in Go, for the stores that can't do it on the db side.
+// The votes are bucketed by the time they were first cast, so changing a vote doesn't move it to the current bucket.
+// Votes without a cast time and suspicious votes are left out
+func computeTrend(productID string, votes []*VoteResult, opts TrendOptions) (*ProductTrend, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	sums := make(map[time.Time]int)
+	buckets := make(map[time.Time]*TrendBucket)
+	for _, v := range votes {
+		cast := createdAt(v)
+		if v.ProductID != productID || v.counted() == nil || cast.IsZero() || !opts.includes(cast) {
+			continue
+		}
+		start := bucketStart(cast, opts.Bucket)
+		if buckets[start] == nil {
+			buckets[start] = &TrendBucket{Start: start}
+		}
+		buckets[start].Count++
+		sums[start] += v.Rate
+	}
+
+	trend := &ProductTrend{ProductID: productID, Bucket: opts.Bucket, Buckets: make([]*TrendBucket, 0, len(buckets))}
+	for start, bucket := range buckets {
+		bucket.Avg = float64(sums[start]) / float64(bucket.Count)
+		trend.Buckets = append(trend.Buckets, bucket)
+	}
+	sort.Slice(trend.Buckets, func(i, j int) bool { return trend.Buckets[i].Start.Before(trend.Buckets[j].Start) })
+	return trend, nil
+}
@@ -0,0 +1,85 @@
+package vote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// votesCastAt returns votes of the product with the rates, first cast at the times
+func votesCastAt(productID string, rates []int, times []time.Time) []*VoteResult {
+	votes := make([]*VoteResult, 0, len(rates))
+	for i, rate := range rates {
+		cast := times[i]
+		votes = append(votes, &VoteResult{ProductID: productID, SessionID: "s" + cast.String(), Rate: rate, CreatedAt: &cast, UpdatedAt: &cast})
+	}
+	return votes
+}
+
+func TestComputeTrend(t *testing.T) {
+	// wednesday
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	votes := votesCastAt("p1",
+		[]int{4, 8, 6, 10, 1},
+		[]time.Time{
+			day.Add(9 * time.Hour),
+			day.Add(9*time.Hour + 30*time.Minute),
+			day.Add(15 * time.Hour),
+			// next monday
+			day.AddDate(0, 0, 5).Add(time.Hour),
+			day.AddDate(0, 0, 6),
+		})
+	// votes of other products and without a cast time are left out
+	votes = append(votes, &VoteResult{ProductID: "p2", Rate: 1, CreatedAt: &day}, &VoteResult{ProductID: "p1", Rate: 1, UpdatedAt: &day})
+
+	// Test case: hours
+	trend, err := computeTrend("p1", votes, TrendOptions{Bucket: BucketHour, To: day.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, &ProductTrend{ProductID: "p1", Bucket: BucketHour, Buckets: []*TrendBucket{
+		{Start: day.Add(9 * time.Hour), Avg: 6, Count: 2},
+		{Start: day.Add(15 * time.Hour), Avg: 6, Count: 1},
+	}}, trend)
+
+	// Test case: days is the default
+	trend, err = computeTrend("p1", votes, TrendOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, BucketDay, trend.Bucket)
+	assert.Equal(t, []*TrendBucket{
+		{Start: day, Avg: 6, Count: 3},
+		{Start: day.AddDate(0, 0, 5), Avg: 10, Count: 1},
+		{Start: day.AddDate(0, 0, 6), Avg: 1, Count: 1},
+	}, trend.Buckets)
+
+	// Test case: weeks start on monday, from is inclusive
+	trend, err = computeTrend("p1", votes, TrendOptions{Bucket: BucketWeek, From: day.Add(15 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []*TrendBucket{
+		{Start: day.AddDate(0, 0, -2), Avg: 6, Count: 1},
+		{Start: day.AddDate(0, 0, 5), Avg: 5.5, Count: 2},
+	}, trend.Buckets)
+
+	// Test case: a changed vote stays in the bucket it was first cast in, with its current rate
+	changed := day.AddDate(0, 0, 6)
+	votes[0].Rate, votes[0].UpdatedAt = 10, &changed
+	trend, err = computeTrend("p1", votes, TrendOptions{To: day.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, []*TrendBucket{{Start: day, Avg: 8, Count: 3}}, trend.Buckets)
+
+	// Test case: invalid options
+	_, err = computeTrend("p1", votes, TrendOptions{Bucket: "month"})
+	assert.Error(t, err)
+	_, err = computeTrend("p1", votes, TrendOptions{From: day, To: day})
+	assert.Error(t, err)
+}
+
+func TestBucketStart(t *testing.T) {
+	// Test case: sunday belongs to the week started on the monday before
+	sunday := time.Date(2024, 5, 5, 23, 59, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC), bucketStart(sunday, BucketWeek))
+
+	// Test case: times are bucketed in UTC
+	local := time.Date(2024, 5, 1, 1, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	assert.Equal(t, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), bucketStart(local, BucketDay))
+}
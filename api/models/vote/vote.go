@@ -1,11 +1,53 @@
 package vote
 
 import (
+	"api_assignment/api/database"
+	"api_assignment/api/models/product"
+	"context"
+	"errors"
+	"time"
+
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Store is the contract every vote storage backend has to fulfil.
+// The handlers only talk to this interface, so the backend (mongo, in-memory, ...) can be chosen at startup.
+// The calls take the context of the request, so the queries are traced as its children
+type Store interface {
+	AllVotes(ctx context.Context) ([]*VoteResult, error)
+	PostVote(ctx context.Context, newVote *VoteResult) (*bool, error)
+	GetVotesBySessionID(ctx context.Context, sessionID string) ([]*VoteResult, error)
+	GetVotesByProductID(ctx context.Context, productID string) ([]*VoteResult, error)
+	GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*ProductVote, error)
+	// ListVotes returns a page of the votes matching the options, ErrInvalidCursor for a bad cursor
+	ListVotes(ctx context.Context, opts ListOptions) (*Page, error)
+	// EachVote calls fn with every vote, one at a time, so large exports don't have to hold all of
+	// them in memory. It stops at the first error of fn or when the context is done
+	EachVote(ctx context.Context, fn func(*VoteResult) error) error
+	// GetVoteHistory returns the changes of the votes of the product, oldest first
+	GetVoteHistory(ctx context.Context, productID string) ([]*VoteChange, error)
+	// DeleteVote removes the vote of the session for the product, ErrNotFound if there is none
+	DeleteVote(ctx context.Context, productID, sessionID string) error
+	// GetProductTrend returns the avg and count of the votes of the product per bucket of time
+	GetProductTrend(ctx context.Context, productID string, opts TrendOptions) (*ProductTrend, error)
+}
+
+// ErrNotFound is returned when the vote to delete does not exist
+var ErrNotFound = errors.New("vote not found")
+
+// VoteModel is the MongoDB implementation of Store
 type VoteModel struct {
 	DB *mongo.Client
+	// DBName is the name of the database holding the collections, database.DefaultMongoDB when empty
+	DBName string
+	// Timeout bounds each call to the db, database.DefaultTimeout when zero.
+	// EachVote is only bounded by its context, exports may take longer
+	Timeout time.Duration
+}
+
+// collection returns the collection with the passed name from the db of the model
+func (vModel VoteModel) collection(name string) *mongo.Collection {
+	return database.MongoDatabase(vModel.DB, vModel.DBName).Collection(name)
 }
 
 // VoteResult holds the data of any vote in the system
@@ -13,12 +55,68 @@ type VoteResult struct {
 	Rate      int    `json:"rate" bson:"rate"`
 	SessionID string `json:"session_id" bson:"session_id"`
 	ProductID string `json:"product_id" bson:"product_id"`
+	// CreatedAt is set by the store when the vote is first cast; it is unknown for older votes
+	CreatedAt *time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	// UpdatedAt is set by the store every time the vote is posted, the first time included
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	// Suspicious is set by the abuse guard, e.g. for votes of sessions created in bulk from one ip.
+	// Suspicious votes are kept but left out of the avgs
+	Suspicious bool `json:"suspicious,omitempty" bson:"suspicious,omitempty"`
+}
+
+// counted returns the vote if it counts towards the avgs, nil otherwise
+func (v *VoteResult) counted() *VoteResult {
+	if v == nil || v.Suspicious {
+		return nil
+	}
+	return v
 }
 
 // ProductVote is a simple container used to hold the avg of the votes of a specific product
 // along with some addiational data
 type ProductVote struct {
-	sum        int
-	Avg        float64 `json:"avg"`
-	VotesCount int     `json:"votes_count"`
+	Avg        float64 `json:"avg" bson:"avg"`
+	VotesCount int     `json:"votes_count" bson:"count"`
+	Sum        int     `json:"sum" bson:"sum"`
+	Min        int     `json:"min" bson:"min"`
+	Max        int     `json:"max" bson:"max"`
+}
+
+// averageVotes calculates the avg of the passed votes per product in Go, without the suspicious votes.
+// It is used by the stores that can't compute it on the db side,
+// products with no votes are included with zero values
+func averageVotes(votes []*VoteResult, products map[string]*product.Product) map[string]*ProductVote {
+	avgVotes := make(map[string]*ProductVote)
+	for _, vote := range votes {
+		if vote.counted() == nil {
+			continue
+		}
+		pv, ok := avgVotes[vote.ProductID]
+		if !ok {
+			pv = &ProductVote{Min: vote.Rate, Max: vote.Rate}
+			avgVotes[vote.ProductID] = pv
+		}
+		pv.Sum += vote.Rate
+		pv.VotesCount++
+		pv.Min = min(pv.Min, vote.Rate)
+		pv.Max = max(pv.Max, vote.Rate)
+	}
+
+	// calculate the avg
+	for prodID := range avgVotes {
+		avgVotes[prodID].Avg = float64(avgVotes[prodID].Sum) / float64(avgVotes[prodID].VotesCount)
+	}
+
+	fillMissingProducts(avgVotes, products)
+	return avgVotes
+}
+
+// fillMissingProducts adds the products with no votes to the avgs with zero values
+func fillMissingProducts(avgVotes map[string]*ProductVote, products map[string]*product.Product) {
+	for prodID := range products {
+		if _, ok := avgVotes[prodID]; !ok {
+			avgVotes[prodID] = &ProductVote{Avg: 0.0, VotesCount: 0}
+
+		}
+	}
 }
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoBackend keeps the buckets in a collection, shared by every instance of the api.
+// A TTL index removes the buckets once they are full again
+type MongoBackend struct {
+	coll *mongo.Collection
+}
+
+// NewMongoBackend creates the backend of the rate_limits collection of the db and its TTL index
+func NewMongoBackend(ctx context.Context, db *mongo.Database) (*MongoBackend, error) {
+	b := &MongoBackend{coll: db.Collection("rate_limits")}
+	_, err := b.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *MongoBackend) NewLimiter(name string, limit Limit) Limiter {
+	return &mongoLimiter{coll: b.coll, name: name, limit: limit, now: time.Now}
+}
+
+// mongoLimiter works like Buckets, with the bucket of each key in a document.
+// The refill and the take are done by a single pipeline update, so concurrent requests can't
+// take the same token
+type mongoLimiter struct {
+	coll  *mongo.Collection
+	name  string
+	limit Limit
+	now   func() time.Time
+}
+
+// bucketDoc is how a bucket is saved in mongo, allowed tells whether the last take got a token
+type bucketDoc struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+func (l *mongoLimiter) Limit() Limit {
+	return l.limit
+}
+
+func (l *mongoLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+	burst := float64(l.limit.burst())
+	interval := float64(l.limit.interval()) / float64(time.Millisecond)
+	full := time.Duration(l.limit.burst()) * l.limit.interval()
+
+	update := mongo.Pipeline{
+		// refill the bucket since its last update, a new bucket starts full. Dates subtract to milliseconds
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: bson.D{{Key: "$min", Value: bson.A{burst, bson.D{{Key: "$add", Value: bson.A{
+				bson.D{{Key: "$ifNull", Value: bson.A{"$tokens", burst}}},
+				bson.D{{Key: "$divide", Value: bson.A{
+					bson.D{{Key: "$max", Value: bson.A{0, bson.D{{Key: "$subtract", Value: bson.A{now, bson.D{{Key: "$ifNull", Value: bson.A{"$updated", now}}}}}}}}},
+					interval,
+				}}},
+			}}}}}}},
+			// the clocks of the instances may be a bit apart, the bucket never goes back in time
+			{Key: "updated", Value: bson.D{{Key: "$max", Value: bson.A{"$updated", now}}}},
+		}}},
+		{{Key: "$set", Value: bson.D{{Key: "allowed", Value: bson.D{{Key: "$gte", Value: bson.A{"$tokens", 1}}}}}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "tokens", Value: bson.D{{Key: "$cond", Value: bson.A{"$allowed", bson.D{{Key: "$subtract", Value: bson.A{"$tokens", 1}}}, "$tokens"}}}},
+			// past it the bucket is full again, as good as a new one
+			{Key: "expires_at", Value: now.Add(full)},
+		}}},
+	}
+
+	filter := bson.D{{Key: "_id", Value: l.name + ":" + key}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	doc := &bucketDoc{}
+	err := l.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(doc)
+	if mongo.IsDuplicateKeyError(err) {
+		// another request created the bucket at the same time, it's there now
+		err = l.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(doc)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(l.limit, doc.Allowed, doc.Tokens), nil
+}
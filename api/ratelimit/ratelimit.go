@@ -0,0 +1,196 @@
+// Package ratelimit limits how often a key (an ip, a session, ...) can do something with token buckets
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit lets Count requests through per Period, in bursts of at most Burst requests.
+// The tokens are refilled continuously, one every Period/Count
+type Limit struct {
+	Count  int
+	Period time.Duration
+	// Burst is the size of the bucket, Count when 0
+	Burst int
+}
+
+// ParseLimit parses a limit written as count/period, e.g. 10/1m, optionally followed by the burst
+// as in 10/1m:20. "off" and the empty string mean no limit and return nil
+func ParseLimit(value string) (*Limit, error) {
+	if value == "" || value == "off" {
+		return nil, nil
+	}
+
+	invalid := fmt.Errorf("invalid limit %q, it must look like 10/1m or 10/1m:20", value)
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	count, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return nil, invalid
+	}
+
+	limit := &Limit{}
+	var err error
+	if limit.Count, err = strconv.Atoi(count); err != nil || limit.Count <= 0 {
+		return nil, invalid
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return nil, invalid
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst <= 0 {
+			return nil, invalid
+		}
+	}
+	return limit, nil
+}
+
+// String formats the limit the way ParseLimit reads it
+func (l Limit) String() string {
+	s := strconv.Itoa(l.Count) + "/" + l.Period.String()
+	if l.Burst > 0 {
+		s += ":" + strconv.Itoa(l.Burst)
+	}
+	return s
+}
+
+// burst returns the size of the bucket
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Count
+}
+
+// interval returns the time it takes to refill a token
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Count)
+}
+
+// Result tells whether a request was let through and the state of its bucket
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests left in the bucket
+	Remaining int
+	// RetryAfter is how long to wait for the next token when the request was not allowed
+	RetryAfter time.Duration
+	// Reset is how long it takes for the bucket to be full again
+	Reset time.Duration
+}
+
+// Limiter takes the tokens of the keys of a limit, from buckets kept in memory or in a db
+type Limiter interface {
+	Limit() Limit
+	// Allow takes a token from the bucket of the key
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Backend creates the limiters. Its limiters are named, so the keys of different limiters don't share buckets
+type Backend interface {
+	NewLimiter(name string, limit Limit) Limiter
+}
+
+// Memory keeps the buckets in the memory of the instance, each instance of the api has its own
+type Memory struct{}
+
+func (Memory) NewLimiter(_ string, limit Limit) Limiter {
+	return NewBuckets(limit)
+}
+
+// bucket holds the tokens of a key. tokens are refilled lazily, from the time of the last update
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills the bucket up to now and takes a token out of it if there is one
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	burst := float64(limit.burst())
+	elapsed := now.Sub(b.updated)
+	b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()/limit.interval().Seconds())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, allowed, b.tokens)
+}
+
+// newResult returns the result of a request, from the tokens left in its bucket
+func newResult(limit Limit, allowed bool, tokens float64) Result {
+	result := Result{Allowed: allowed, Remaining: int(tokens)}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(limit.interval()))
+	}
+	result.Reset = time.Duration((float64(limit.burst()) - tokens) * float64(limit.interval()))
+	return result
+}
+
+// Buckets keeps a token bucket per key in memory, safe for concurrent use.
+// The buckets that are full again are dropped from time to time, so memory only grows with the active keys
+type Buckets struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is replaced in the tests
+	now func() time.Time
+}
+
+// NewBuckets creates the buckets of the limit
+func NewBuckets(limit Limit) *Buckets {
+	return &Buckets{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Limit returns the limit of the buckets
+func (b *Buckets) Limit() Limit {
+	return b.limit
+}
+
+// Allow takes a token from the bucket of the key, it never fails
+func (b *Buckets) Allow(_ context.Context, key string) (Result, error) {
+	return b.Take(key), nil
+}
+
+// Take takes a token from the bucket of the key, a new key starts with a full bucket
+func (b *Buckets) Take(key string) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	bkt, ok := b.buckets[key]
+	if !ok {
+		bkt = &bucket{tokens: float64(b.limit.burst()), updated: now}
+		b.buckets[key] = bkt
+	}
+	return bkt.take(b.limit, now)
+}
+
+// sweep drops the buckets that were refilled since their last update, at most once per period.
+// It must be called with the lock held
+func (b *Buckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.limit.Period {
+		return
+	}
+	full := time.Duration(b.limit.burst()) * b.limit.interval()
+	for key, bkt := range b.buckets {
+		if now.Sub(bkt.updated) >= full {
+			delete(b.buckets, key)
+		}
+	}
+	b.lastSweep = now
+}
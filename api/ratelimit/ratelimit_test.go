@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10/1m")
+	require.NoError(t, err)
+	assert.Equal(t, &Limit{Count: 10, Period: time.Minute}, limit)
+
+	limit, err = ParseLimit("5/1h:20")
+	require.NoError(t, err)
+	assert.Equal(t, &Limit{Count: 5, Period: time.Hour, Burst: 20}, limit)
+	assert.Equal(t, "5/1h0m0s:20", limit.String())
+
+	// Test case: no limit
+	for _, value := range []string{"", "off"} {
+		limit, err = ParseLimit(value)
+		assert.NoError(t, err)
+		assert.Nil(t, limit)
+	}
+
+	// Test case: invalid limits
+	for _, value := range []string{"10", "10/", "x/1m", "0/1m", "10/0s", "10/1m:0", "10/1m:x", "-1/1m"} {
+		_, err := ParseLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	buckets := NewBuckets(Limit{Count: 2, Period: time.Minute, Burst: 3})
+	buckets.now = func() time.Time { return now }
+	buckets.lastSweep = now
+
+	// Test case: the burst goes through, then the requests wait for the refill
+	for i := 2; i >= 0; i-- {
+		result := buckets.Take("a")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result := buckets.Take("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+	assert.Equal(t, 90*time.Second, result.Reset)
+
+	// Test case: keys have their own buckets
+	assert.True(t, buckets.Take("b").Allowed)
+
+	// Test case: a token is refilled every period / count
+	now = now.Add(30 * time.Second)
+	assert.True(t, buckets.Take("a").Allowed)
+	assert.False(t, buckets.Take("a").Allowed)
+
+	// Test case: the full buckets are dropped after a period
+	now = now.Add(2 * time.Minute)
+	buckets.Take("c")
+	assert.Len(t, buckets.buckets, 1)
+}
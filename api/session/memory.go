@@ -0,0 +1,64 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how often the memory backend drops the expired sessions
+const sweepEvery = time.Minute
+
+// memoryBackend keeps the sessions in memory, they are lost on restart and not shared between instances
+type memoryBackend struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data      string
+	expiresAt time.Time
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{sessions: make(map[string]memorySession), lastSweep: time.Now()}
+}
+
+func (b *memoryBackend) load(_ context.Context, id string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved, ok := b.sessions[id]
+	if !ok || !time.Now().Before(saved.expiresAt) {
+		return "", errNotFound
+	}
+	return saved.data, nil
+}
+
+// save also drops the expired sessions, at most once every sweepEvery
+func (b *memoryBackend) save(_ context.Context, id, data string, expiresAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.lastSweep) >= sweepEvery {
+		for savedID, saved := range b.sessions {
+			if !now.Before(saved.expiresAt) {
+				delete(b.sessions, savedID)
+			}
+		}
+		b.lastSweep = now
+	}
+
+	b.sessions[id] = memorySession{data: data, expiresAt: expiresAt}
+	return nil
+}
+
+func (b *memoryBackend) erase(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sessions, id)
+	return nil
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoBackend keeps the sessions in a collection, shared by every instance of the api.
+// A TTL index removes the expired sessions
+type mongoBackend struct {
+	coll *mongo.Collection
+}
+
+// sessionDoc is how a session is saved in mongo
+type sessionDoc struct {
+	ID        string    `bson:"_id"`
+	Data      string    `bson:"data"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// ensureIndexes creates the TTL index. Mongo removes the expired documents about once a minute,
+// so load checks the expiry as well
+func (b *mongoBackend) ensureIndexes(ctx context.Context) error {
+	_, err := b.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (b *mongoBackend) load(ctx context.Context, id string) (string, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}
+
+	doc := &sessionDoc{}
+	err := b.coll.FindOne(ctx, filter).Decode(doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", errNotFound
+	}
+	return doc.Data, err
+}
+
+func (b *mongoBackend) save(ctx context.Context, id, data string, expiresAt time.Time) error {
+	doc := &sessionDoc{ID: id, Data: data, ExpiresAt: expiresAt}
+	_, err := b.coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (b *mongoBackend) erase(ctx context.Context, id string) error {
+	_, err := b.coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	return err
+}
@@ -0,0 +1,132 @@
+package session
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// errNotFound is returned by the backends for unknown or expired sessions
+var errNotFound = errors.New("session not found")
+
+// errNotLoaded is returned when saving a session whose backend failed to load it
+var errNotLoaded = errors.New("the session could not be loaded, it is not saved")
+
+// notLoadedKey marks, in its values, a session the backend failed to load
+type notLoadedKey struct{}
+
+// backend saves the encoded values of the sessions by their id
+type backend interface {
+	load(ctx context.Context, id string) (string, error)
+	save(ctx context.Context, id, data string, expiresAt time.Time) error
+	erase(ctx context.Context, id string) error
+}
+
+// serverStore keeps the values of the sessions in a backend, the cookie only holds the signed id
+// of the session. It works like the FilesystemStore of gorilla, with the files replaced by the backend
+type serverStore struct {
+	codecs  []securecookie.Codec
+	options *gsessions.Options
+	backend backend
+}
+
+func newServerStore(backend backend, keyPairs [][]byte) *serverStore {
+	return &serverStore{
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		options: &gsessions.Options{Path: "/", MaxAge: int(DefaultMaxAge.Seconds())},
+		backend: backend,
+	}
+}
+
+// Options sets the options of the cookies, the max age is also the lifetime of the saved sessions
+func (s *serverStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+	for _, codec := range s.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(options.MaxAge)
+		}
+	}
+}
+
+// Get returns the session of the request, cached in the registry of the request
+func (s *serverStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session of the cookie, a new session when there is no cookie or the session expired
+func (s *serverStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecs...); err != nil {
+		// signed with an unknown key or tampered with
+		session.ID = ""
+		return session, err
+	}
+
+	data, err := s.backend.load(r.Context(), session.ID)
+	if errors.Is(err, errNotFound) {
+		// expired, a new session is started with a new id
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		// e.g. the db is down. The stored session may still exist: saving this one, which gin-contrib
+		// does even though the error is dropped, would replace it and lose the votes of the voter
+		session.Values[notLoadedKey{}] = true
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, data, &session.Values, s.codecs...); err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save saves the values of the session and sets the cookie holding its id.
+// A session with a max age <= 0 is erased
+func (s *serverStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.backend.erase(r.Context(), session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.Values[notLoadedKey{}] != nil {
+		return errNotLoaded
+	}
+
+	if session.ID == "" {
+		session.ID = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second)
+	if err := s.backend.save(r.Context(), session.ID, data, expiresAt); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,151 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRouter returns a router counting the requests of each session in the store
+func setupRouter(store sessions.Store) *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("session_cookie", store))
+	router.GET("/", func(c *gin.Context) {
+		session := sessions.Default(c)
+		count, _ := session.Get("count").(int)
+		session.Set("count", count+1)
+		session.Save()
+		c.String(http.StatusOK, "%d", count+1)
+	})
+	return router
+}
+
+// get requests the router with the cookies and returns the body and the cookie of the response
+func get(router *gin.Engine, cookies ...*http.Cookie) (string, *http.Cookie) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(w, req)
+
+	var cookie *http.Cookie
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		cookie = cookies[0]
+	}
+	return w.Body.String(), cookie
+}
+
+func TestServerStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	oldKeys, err := ParseKeys([]string{testKey('a', 32)})
+	require.NoError(t, err)
+	newKeys, err := ParseKeys([]string{testKey('b', 32)})
+	require.NoError(t, err)
+
+	backend := newMemoryBackend()
+	store := newServerStore(backend, oldKeys)
+	store.Options(sessions.Options{Path: "/", MaxAge: 3600, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	router := setupRouter(store)
+
+	// Test case: the values stay on the server, the cookie only holds the id
+	body, cookie := get(router)
+	assert.Equal(t, "1", body)
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, 3600, cookie.MaxAge)
+	assert.Len(t, backend.sessions, 1)
+
+	body, _ = get(router, cookie)
+	assert.Equal(t, "2", body)
+
+	// Test case: after a rotation the cookies signed with the old key are still accepted
+	rotated := setupRouter(newServerStore(backend, append(newKeys, oldKeys...)))
+	body, _ = get(rotated, cookie)
+	assert.Equal(t, "3", body)
+
+	// Test case: once the old key is dropped, its cookies start a new session
+	dropped := setupRouter(newServerStore(backend, newKeys))
+	body, newCookie := get(dropped, cookie)
+	assert.Equal(t, "1", body)
+	assert.NotEqual(t, cookie.Value, newCookie.Value)
+
+	// Test case: a tampered cookie starts a new session
+	tampered := *cookie
+	tampered.Value = "x" + tampered.Value[1:]
+	body, _ = get(router, &tampered)
+	assert.Equal(t, "1", body)
+
+	// Test case: an expired session starts a new one
+	for id := range backend.sessions {
+		backend.save(context.Background(), id, "", time.Now().Add(-time.Second))
+	}
+	body, _ = get(router, cookie)
+	assert.Equal(t, "1", body)
+}
+
+// flakyBackend fails to load the sessions while down
+type flakyBackend struct {
+	*memoryBackend
+	down bool
+}
+
+func (b *flakyBackend) load(ctx context.Context, id string) (string, error) {
+	if b.down {
+		return "", errors.New("connection refused")
+	}
+	return b.memoryBackend.load(ctx, id)
+}
+
+func TestServerStoreLoadFails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys, err := ParseKeys([]string{testKey('a', 32)})
+	require.NoError(t, err)
+	backend := &flakyBackend{memoryBackend: newMemoryBackend()}
+	router := setupRouter(newServerStore(backend, keys))
+
+	body, cookie := get(router)
+	assert.Equal(t, "1", body)
+	require.NotNil(t, cookie)
+	body, _ = get(router, cookie)
+	assert.Equal(t, "2", body)
+
+	// Test case: a failed load neither replaces the stored session nor the cookie
+	backend.down = true
+	_, newCookie := get(router, cookie)
+	assert.Nil(t, newCookie)
+	assert.Len(t, backend.sessions, 1)
+
+	// the voter gets their session back once the db is up again
+	backend.down = false
+	body, _ = get(router, cookie)
+	assert.Equal(t, "3", body)
+}
+
+func TestNewStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Test case: the cookie store works without keys, with a random one
+	store, err := NewStore(context.Background(), Config{Store: Cookie, Options: sessions.Options{Path: "/", MaxAge: 60}}, nil)
+	require.NoError(t, err)
+	router := setupRouter(store)
+	body, cookie := get(router)
+	assert.Equal(t, "1", body)
+	body, _ = get(router, cookie)
+	assert.Equal(t, "2", body)
+
+	// Test case: the mongo store needs the mongo client
+	_, err = NewStore(context.Background(), Config{Store: Mongo}, nil)
+	assert.Error(t, err)
+}
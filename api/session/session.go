@@ -0,0 +1,211 @@
+// Package session configures the store of the cookie sessions, identifying the voters.
+// The sessions are either kept in the cookie itself or server side, in memory or in mongo,
+// with only their signed id in the cookie
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gorilla/securecookie"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// supported stores
+const (
+	// Cookie keeps the values of the session in the cookie, signed and optionally encrypted
+	Cookie = "cookie"
+	Memory = "memory"
+	Mongo  = "mongo"
+)
+
+// DefaultMaxAge is how long the sessions last when no max age is configured
+const DefaultMaxAge = 30 * 24 * time.Hour
+
+// Config selects the session store and configures its keys and cookie
+type Config struct {
+	// Store is one of Cookie, Memory or Mongo
+	Store string
+	// KeyPairs are the authentication and encryption keys, alternating, as gorilla expects them.
+	// The first pair signs (and encrypts) the new cookies, all of them are tried to read a cookie,
+	// so keys can be rotated by prepending the new pair and dropping the old one later
+	KeyPairs [][]byte
+	Options  sessions.Options
+}
+
+// ConfigFromEnv reads the config from the env variables:
+//
+//   - SESSION_STORE: cookie (default), memory or mongo
+//   - SESSION_KEYS: comma separated key pairs, newest first, see ParseKeys
+//   - SESSION_KEYS_FILE: file with a key pair per line, newest first, used when SESSION_KEYS is empty
+//   - SESSION_COOKIE_SECURE, SESSION_COOKIE_HTTP_ONLY: true or false, by default false and true
+//   - SESSION_COOKIE_SAME_SITE: lax (default), strict or none
+//   - SESSION_COOKIE_MAX_AGE: a duration like 720h, DefaultMaxAge by default
+//   - SESSION_COOKIE_DOMAIN: empty by default, the cookie is for the host of the api only
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Store: os.Getenv("SESSION_STORE"),
+		Options: sessions.Options{
+			Path:     "/",
+			Domain:   os.Getenv("SESSION_COOKIE_DOMAIN"),
+			MaxAge:   int(DefaultMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+	if cfg.Store == "" {
+		cfg.Store = Cookie
+	}
+	if cfg.Store != Cookie && cfg.Store != Memory && cfg.Store != Mongo {
+		return cfg, fmt.Errorf("SESSION_STORE must be cookie, memory or mongo, not %q", cfg.Store)
+	}
+
+	var err error
+	if keys := os.Getenv("SESSION_KEYS"); keys != "" {
+		cfg.KeyPairs, err = ParseKeys(strings.Split(keys, ","))
+	} else if path := os.Getenv("SESSION_KEYS_FILE"); path != "" {
+		cfg.KeyPairs, err = ReadKeysFile(path)
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Options.Secure, err = envBool("SESSION_COOKIE_SECURE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.Options.HttpOnly, err = envBool("SESSION_COOKIE_HTTP_ONLY", true); err != nil {
+		return cfg, err
+	}
+	switch sameSite := strings.ToLower(os.Getenv("SESSION_COOKIE_SAME_SITE")); sameSite {
+	case "", "lax":
+	case "strict":
+		cfg.Options.SameSite = http.SameSiteStrictMode
+	case "none":
+		// browsers drop the SameSite=None cookies that are not secure
+		if !cfg.Options.Secure {
+			return cfg, errors.New("SESSION_COOKIE_SAME_SITE=none needs SESSION_COOKIE_SECURE=true")
+		}
+		cfg.Options.SameSite = http.SameSiteNoneMode
+	default:
+		return cfg, fmt.Errorf("SESSION_COOKIE_SAME_SITE must be lax, strict or none, not %q", sameSite)
+	}
+	if maxAge := os.Getenv("SESSION_COOKIE_MAX_AGE"); maxAge != "" {
+		age, err := time.ParseDuration(maxAge)
+		if err != nil || age < time.Second {
+			return cfg, fmt.Errorf("SESSION_COOKIE_MAX_AGE must be a duration of at least a second, like 720h, not %q", maxAge)
+		}
+		cfg.Options.MaxAge = int(age.Seconds())
+	}
+	return cfg, nil
+}
+
+// envBool reads a boolean env variable, def when it is not set
+func envBool(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, fmt.Errorf("%s must be true or false, not %q", name, value)
+	}
+	return b, nil
+}
+
+// ParseKeys decodes the key pairs, each one is the base64 authentication key optionally followed
+// by a colon and the base64 encryption key, e.g. generated with `openssl rand -base64 32`.
+// Authentication keys must be at least 32 bytes, encryption keys 16, 24 or 32 bytes (AES-128, 192 or 256)
+func ParseKeys(pairs []string) ([][]byte, error) {
+	keys := make([][]byte, 0, 2*len(pairs))
+	for i, pair := range pairs {
+		pair = strings.TrimSpace(pair)
+		authKey, encKey, _ := strings.Cut(pair, ":")
+
+		auth, err := base64.StdEncoding.DecodeString(authKey)
+		if err != nil {
+			return nil, fmt.Errorf("session key %d: authentication key is not base64", i+1)
+		}
+		if len(auth) < 32 {
+			return nil, fmt.Errorf("session key %d: authentication key must be at least 32 bytes", i+1)
+		}
+
+		var enc []byte
+		if encKey != "" {
+			if enc, err = base64.StdEncoding.DecodeString(encKey); err != nil {
+				return nil, fmt.Errorf("session key %d: encryption key is not base64", i+1)
+			}
+			if len(enc) != 16 && len(enc) != 24 && len(enc) != 32 {
+				return nil, fmt.Errorf("session key %d: encryption key must be 16, 24 or 32 bytes", i+1)
+			}
+		}
+		keys = append(keys, auth, enc)
+	}
+	return keys, nil
+}
+
+// ReadKeysFile reads the key pairs of the file, one per line. Empty lines and lines starting with # are skipped
+func ReadKeysFile(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var pairs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			pairs = append(pairs, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("%s holds no session keys", path)
+	}
+	return ParseKeys(pairs)
+}
+
+// NewStore creates the store of the config. The mongo store saves the sessions in the db,
+// which is only needed for it.
+// Without keys a random one is generated, so the sessions don't survive a restart
+func NewStore(ctx context.Context, cfg Config, db *mongo.Database) (sessions.Store, error) {
+	keyPairs := cfg.KeyPairs
+	if len(keyPairs) == 0 {
+		slog.Warn("no SESSION_KEYS set, using a random key; sessions will not survive a restart")
+		keyPairs = [][]byte{securecookie.GenerateRandomKey(64), nil}
+	}
+
+	var store sessions.Store
+	switch cfg.Store {
+	case Memory:
+		store = newServerStore(newMemoryBackend(), keyPairs)
+	case Mongo:
+		if db == nil {
+			return nil, errors.New("the mongo session store needs the mongo storage")
+		}
+		backend := &mongoBackend{coll: db.Collection("sessions")}
+		if err := backend.ensureIndexes(ctx); err != nil {
+			return nil, err
+		}
+		store = newServerStore(backend, keyPairs)
+	default:
+		store = cookie.NewStore(keyPairs...)
+	}
+
+	store.Options(cfg.Options)
+	return store, nil
+}
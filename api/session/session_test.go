@@ -0,0 +1,106 @@
+package session
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a base64 key of n bytes, all set to b
+func testKey(b byte, n int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), n)))
+}
+
+func TestParseKeys(t *testing.T) {
+	// Test case: authentication only and authentication with encryption keys
+	keys, err := ParseKeys([]string{testKey('a', 32), " " + testKey('b', 64) + ":" + testKey('c', 16)})
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{
+		[]byte(strings.Repeat("a", 32)), nil,
+		[]byte(strings.Repeat("b", 64)), []byte(strings.Repeat("c", 16)),
+	}, keys)
+
+	// Test case: invalid keys
+	for _, pair := range []string{
+		"not base64!",
+		testKey('a', 16),
+		testKey('a', 32) + ":" + testKey('c', 20),
+		testKey('a', 32) + ":not base64!",
+	} {
+		_, err := ParseKeys([]string{pair})
+		assert.Error(t, err, pair)
+	}
+}
+
+func TestReadKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# new key\n" + testKey('b', 32) + "\n\n# old key, drop after a max age\n" + testKey('a', 32) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	keys, err := ReadKeysFile(path)
+	require.NoError(t, err)
+	assert.Len(t, keys, 4)
+	assert.Equal(t, []byte(strings.Repeat("b", 32)), keys[0])
+
+	// Test case: no keys
+	require.NoError(t, os.WriteFile(path, []byte("# nothing yet\n"), 0600))
+	_, err = ReadKeysFile(path)
+	assert.Error(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	// Test case: defaults
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Cookie, cfg.Store)
+	assert.Empty(t, cfg.KeyPairs)
+	assert.True(t, cfg.Options.HttpOnly)
+	assert.False(t, cfg.Options.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cfg.Options.SameSite)
+	assert.Equal(t, int(DefaultMaxAge.Seconds()), cfg.Options.MaxAge)
+
+	// Test case: everything set
+	t.Setenv("SESSION_STORE", "memory")
+	t.Setenv("SESSION_KEYS", testKey('b', 32)+","+testKey('a', 32))
+	t.Setenv("SESSION_COOKIE_SECURE", "true")
+	t.Setenv("SESSION_COOKIE_HTTP_ONLY", "false")
+	t.Setenv("SESSION_COOKIE_SAME_SITE", "none")
+	t.Setenv("SESSION_COOKIE_MAX_AGE", "24h")
+	t.Setenv("SESSION_COOKIE_DOMAIN", "example.com")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Memory, cfg.Store)
+	assert.Len(t, cfg.KeyPairs, 4)
+	assert.True(t, cfg.Options.Secure)
+	assert.False(t, cfg.Options.HttpOnly)
+	assert.Equal(t, http.SameSiteNoneMode, cfg.Options.SameSite)
+	assert.Equal(t, int((24 * time.Hour).Seconds()), cfg.Options.MaxAge)
+	assert.Equal(t, "example.com", cfg.Options.Domain)
+
+	// Test case: invalid values
+	for name, value := range map[string]string{
+		"SESSION_STORE":            "redis",
+		"SESSION_KEYS":             "short",
+		"SESSION_COOKIE_SECURE":    "yes please",
+		"SESSION_COOKIE_SAME_SITE": "sometimes",
+		"SESSION_COOKIE_MAX_AGE":   "forever",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := ConfigFromEnv()
+			assert.Error(t, err)
+		})
+	}
+
+	// Test case: SameSite=None cookies must be secure
+	t.Setenv("SESSION_COOKIE_SECURE", "false")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"api_assignment/api/database"
+	"api_assignment/api/models/apikey"
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// supported backends
+const (
+	Mongo    = "mongo"
+	Memory   = "memory"
+	SQLite   = database.SQLite
+	Postgres = database.Postgres
+)
+
+// Config selects and configures the storage backend
+type Config struct {
+	// Backend is one of Mongo, Memory, SQLite or Postgres
+	Backend string
+	// DatabaseURL is the dsn of the sql backends
+	DatabaseURL string
+	// ProductsFile holds the products the memory backend starts with, and the ones seeded to the
+	// sql backends when SeedProducts is set
+	ProductsFile string
+	SeedProducts bool
+	// Timeout bounds each call of the vote and product stores to the db, database.DefaultTimeout when zero
+	Timeout time.Duration
+	// MongoDB is the name of the database of the mongo backend, database.DefaultMongoDB when empty
+	MongoDB string
+}
+
+// ConfigFromEnv reads the config from the STORAGE, DATABASE_URL, PRODUCTS_FILE and MONGO_DB env variables.
+// mongo is the default backend, and the mongo connection is read from the other MONGO_* variables on Open
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Backend:      os.Getenv("STORAGE"),
+		DatabaseURL:  os.Getenv("DATABASE_URL"),
+		ProductsFile: os.Getenv("PRODUCTS_FILE"),
+		MongoDB:      database.MongoDBFromEnv(),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = Mongo
+	}
+	if cfg.ProductsFile == "" {
+		cfg.ProductsFile = "products.json"
+	}
+	if cfg.DatabaseURL == "" && cfg.Backend == SQLite {
+		cfg.DatabaseURL = "votes.db"
+	}
+	return cfg
+}
+
+// Storage holds the stores of the selected backend
+type Storage struct {
+	Votes    vote.Store
+	Products product.Store
+	Keys     apikey.Store
+
+	// MongoClient and MongoDB, the database of the stores, are set for the mongo backend
+	MongoClient *mongo.Client
+	MongoDB     *mongo.Database
+	// SQL is set for the sql backends
+	SQL *database.DB
+}
+
+// Open connects to the backend of the config and creates its stores
+func Open(ctx context.Context, cfg Config) (*Storage, error) {
+	switch cfg.Backend {
+	case Memory:
+		prs, err := product.ReadProductsFile(cfg.ProductsFile)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Votes:    vote.NewMemoryStore(),
+			Products: product.NewMemoryStore(prs...),
+			Keys:     apikey.NewMemoryStore(),
+		}, nil
+
+	case SQLite, Postgres:
+		db, err := database.Open(cfg.Backend, cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		sqlProducts := product.SQLStore{DB: db, Timeout: cfg.Timeout}
+		if cfg.SeedProducts {
+			// already existing products are skipped
+			prs, err := product.ReadProductsFile(cfg.ProductsFile)
+			if err != nil {
+				db.Close()
+				return nil, err
+			}
+			if err := sqlProducts.AddProducts(ctx, prs); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+
+		return &Storage{
+			Votes:    vote.SQLStore{DB: db, Timeout: cfg.Timeout},
+			Products: sqlProducts,
+			Keys:     apikey.SQLStore{DB: db, Timeout: cfg.Timeout},
+			SQL:      db,
+		}, nil
+
+	case Mongo:
+		client, err := database.ConnectMongo(ctx, database.MongoURIFromEnv())
+		if err != nil {
+			return nil, err
+		}
+
+		vModel := vote.VoteModel{DB: client, DBName: cfg.MongoDB, Timeout: cfg.Timeout}
+		if err := vModel.EnsureIndexes(ctx); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+		pModel := product.ProductModel{DB: client, DBName: cfg.MongoDB, Timeout: cfg.Timeout}
+		if err := pModel.EnsureIndexes(ctx); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+		kModel := apikey.KeyModel{DB: client, DBName: cfg.MongoDB, Timeout: cfg.Timeout}
+		if err := kModel.EnsureIndexes(ctx); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+
+		return &Storage{
+			Votes:       vModel,
+			Products:    pModel,
+			Keys:        kModel,
+			MongoClient: client,
+			MongoDB:     database.MongoDatabase(client, cfg.MongoDB),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage %q, expected mongo, memory, sqlite or postgres", cfg.Backend)
+}
+
+// Ping checks the db of the backend can be reached, the memory backend always can
+func (s *Storage) Ping(ctx context.Context) error {
+	if s.MongoClient != nil {
+		return s.MongoClient.Ping(ctx, readpref.Primary())
+	}
+	if s.SQL != nil {
+		return s.SQL.PingContext(ctx)
+	}
+	return nil
+}
+
+// Close releases the connections of the backend
+func (s *Storage) Close() error {
+	if s.MongoClient != nil {
+		return s.MongoClient.Disconnect(context.Background())
+	}
+	if s.SQL != nil {
+		return s.SQL.Close()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package tracing sets up the OpenTelemetry tracing of the api: the exporter of the spans and the
+// propagation of the W3C trace context, so the spans of a request join the trace of its caller
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName is the name of the api in the traces, unless OTEL_SERVICE_NAME replaces it
+const ServiceName = "products-vote"
+
+// exporters of the spans
+const (
+	// None drops the spans, the trace context is still propagated
+	None = "none"
+	// OTLP sends the spans to an OpenTelemetry collector over http
+	OTLP = "otlp"
+	// Console prints the spans to stdout, indented
+	Console = "console"
+	// File appends the spans to a file, one json object per line
+	File = "file"
+)
+
+// Config selects where the spans are exported
+type Config struct {
+	// Exporter is None, OTLP, Console or File
+	Exporter string
+	// File is the path the File exporter appends to
+	File string
+}
+
+// ConfigFromEnv reads the config from the env variables:
+//   - OTEL_TRACES_EXPORTER: none (default), otlp, console or file
+//   - OTEL_TRACES_FILE: the file of the file exporter, traces.jsonl by default
+//
+// The otlp exporter reads its endpoint and headers from the standard OTEL_EXPORTER_OTLP_* variables
+// and the sampler from OTEL_TRACES_SAMPLER, every trace is sampled by default
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Exporter: strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")), File: os.Getenv("OTEL_TRACES_FILE")}
+	if cfg.Exporter == "" {
+		cfg.Exporter = None
+	}
+	if cfg.Exporter == "stdout" {
+		cfg.Exporter = Console
+	}
+	if cfg.Exporter != None && cfg.Exporter != OTLP && cfg.Exporter != Console && cfg.Exporter != File {
+		return cfg, fmt.Errorf("OTEL_TRACES_EXPORTER must be none, otlp, console or file, not %q", cfg.Exporter)
+	}
+	if cfg.File == "" {
+		cfg.File = "traces.jsonl"
+	}
+	return cfg, nil
+}
+
+// Setup installs the tracer provider of the config and the W3C trace context propagator as the globals of otel.
+// The returned shutdown flushes the buffered spans and closes the exporter, it has to be called before exiting
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Exporter == None {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, errors.Join(err, closeOutput())
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closeOutput())
+	}, nil
+}
+
+// newExporter creates the exporter of the config, along with the closing of the file it writes to
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, func() error, error) {
+	noClose := func() error { return nil }
+
+	switch cfg.Exporter {
+	case OTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		return exporter, noClose, err
+	case Console:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		return exporter, noClose, err
+	case File:
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return nil, nil, errors.Join(err, file.Close())
+		}
+		return exporter, file.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unknown exporter %q", cfg.Exporter)
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"api_assignment/api/models/vote"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans makes the global tracer provider record the ended spans for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	t.Setenv("OTEL_TRACES_FILE", "")
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Config{Exporter: None, File: "traces.jsonl"}, cfg)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Console, cfg.Exporter)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	t.Setenv("OTEL_TRACES_FILE", "/tmp/spans.jsonl")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Config{Exporter: File, File: "/tmp/spans.jsonl"}, cfg)
+
+	// Test case: unknown exporter
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestSetupFile(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), Config{Exporter: File, File: path})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "GetAverageVotesForAllProductsHandler")
+	span.End()
+	// the spans are buffered until the shutdown
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+	exported := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &exported))
+	assert.Equal(t, "GetAverageVotesForAllProductsHandler", exported["Name"])
+}
+
+func TestTraceVotes(t *testing.T) {
+	recorder := recordSpans(t)
+	store := TraceVotes(vote.NewMemoryStore())
+
+	_, err := store.PostVote(context.Background(), &vote.VoteResult{ProductID: "p1", SessionID: "s1", Rate: 5})
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.DeleteVote(context.Background(), "p2", "s1"), vote.ErrNotFound)
+	_, err = store.ListVotes(context.Background(), vote.ListOptions{Cursor: "invalid"})
+	assert.ErrorIs(t, err, vote.ErrInvalidCursor)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "vote.Store/PostVote", spans[0].Name())
+	assert.Equal(t, "vote.Store/DeleteVote", spans[1].Name())
+
+	// Test case: the errors the handlers expect are not failures of the store
+	for _, span := range spans {
+		assert.Equal(t, codes.Unset, span.Status().Code, span.Name())
+	}
+
+	// Test case: a failed call marks its span
+	store = TraceVotes(failingStore{vote.NewMemoryStore()})
+	_, err = store.GetVoteHistory(context.Background(), "p1")
+	require.Error(t, err)
+	failed := recorder.Ended()[3]
+	assert.Equal(t, "vote.Store/GetVoteHistory", failed.Name())
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "db is down", failed.Status().Description)
+}
+
+// failingStore fails to return the history of the votes
+type failingStore struct {
+	vote.Store
+}
+
+func (failingStore) GetVoteHistory(context.Context, string) ([]*vote.VoteChange, error) {
+	return nil, errors.New("db is down")
+}
+
+func TestTracePropagation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := recordSpans(t)
+	_, err := Setup(context.Background(), Config{Exporter: None})
+	require.NoError(t, err)
+	store := TraceVotes(vote.NewMemoryStore())
+
+	router := gin.New()
+	router.Use(otelgin.Middleware(ServiceName))
+	router.GET("/products/avgs", func(c *gin.Context) {
+		store.GetAverageVotesForAllProducts(c.Request.Context(), nil)
+		c.Status(http.StatusOK)
+	})
+
+	// Test case: the spans join the trace of the traceparent of the caller
+	req := httptest.NewRequest(http.MethodGet, "/products/avgs", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	storeSpan, requestSpan := spans[0], spans[1]
+	assert.Equal(t, "/products/avgs", requestSpan.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestSpan.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", requestSpan.Parent().SpanID().String())
+	assert.True(t, requestSpan.Parent().IsRemote())
+
+	// the call to the store is a child of the request
+	assert.Equal(t, requestSpan.SpanContext().TraceID(), storeSpan.SpanContext().TraceID())
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), storeSpan.Parent().SpanID())
+}
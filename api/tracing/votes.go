@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the package in the spans
+const instrumentationName = "api_assignment/api/tracing"
+
+// tracedVotes starts a span for every call to a vote store. The queries of the mongo driver are
+// children of these spans, so the time spent in the store and in the db can be told apart
+type tracedVotes struct {
+	store  vote.Store
+	tracer trace.Tracer
+}
+
+// TraceVotes wraps the store so its calls are traced with the global tracer provider
+func TraceVotes(store vote.Store) vote.Store {
+	return &tracedVotes{store: store, tracer: otel.Tracer(instrumentationName)}
+}
+
+// start starts the span of the method
+func (s *tracedVotes) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "vote.Store/"+method, trace.WithAttributes(attrs...))
+}
+
+// end ends the span, marking it as failed for an error. The errors the handlers expect,
+// like an unknown vote or a client going away, are not failures of the store
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, vote.ErrNotFound) && !errors.Is(err, vote.ErrInvalidCursor) && !errors.Is(err, context.Canceled) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *tracedVotes) AllVotes(ctx context.Context) ([]*vote.VoteResult, error) {
+	ctx, span := s.start(ctx, "AllVotes")
+	votes, err := s.store.AllVotes(ctx)
+	span.SetAttributes(attribute.Int("votes.count", len(votes)))
+	end(span, err)
+	return votes, err
+}
+
+func (s *tracedVotes) PostVote(ctx context.Context, newVote *vote.VoteResult) (*bool, error) {
+	ctx, span := s.start(ctx, "PostVote", attribute.String("product.id", newVote.ProductID))
+	alreadyExist, err := s.store.PostVote(ctx, newVote)
+	end(span, err)
+	return alreadyExist, err
+}
+
+func (s *tracedVotes) GetVotesBySessionID(ctx context.Context, sessionID string) ([]*vote.VoteResult, error) {
+	// the session id is secret, like in the logs
+	ctx, span := s.start(ctx, "GetVotesBySessionID")
+	votes, err := s.store.GetVotesBySessionID(ctx, sessionID)
+	span.SetAttributes(attribute.Int("votes.count", len(votes)))
+	end(span, err)
+	return votes, err
+}
+
+func (s *tracedVotes) GetVotesByProductID(ctx context.Context, productID string) ([]*vote.VoteResult, error) {
+	ctx, span := s.start(ctx, "GetVotesByProductID", attribute.String("product.id", productID))
+	votes, err := s.store.GetVotesByProductID(ctx, productID)
+	span.SetAttributes(attribute.Int("votes.count", len(votes)))
+	end(span, err)
+	return votes, err
+}
+
+func (s *tracedVotes) GetAverageVotesForAllProducts(ctx context.Context, products map[string]*product.Product) (map[string]*vote.ProductVote, error) {
+	ctx, span := s.start(ctx, "GetAverageVotesForAllProducts", attribute.Int("products.count", len(products)))
+	avgs, err := s.store.GetAverageVotesForAllProducts(ctx, products)
+	end(span, err)
+	return avgs, err
+}
+
+func (s *tracedVotes) ListVotes(ctx context.Context, opts vote.ListOptions) (*vote.Page, error) {
+	ctx, span := s.start(ctx, "ListVotes", attribute.String("votes.sort", opts.Sort), attribute.Int("votes.limit", opts.Limit))
+	page, err := s.store.ListVotes(ctx, opts)
+	if page != nil {
+		span.SetAttributes(attribute.Int("votes.count", len(page.Votes)))
+	}
+	end(span, err)
+	return page, err
+}
+
+// EachVote is traced until the last vote is handled by fn, e.g. written to the client.
+// The errors of fn are not failures of the store
+func (s *tracedVotes) EachVote(ctx context.Context, fn func(*vote.VoteResult) error) error {
+	ctx, span := s.start(ctx, "EachVote")
+	count := 0
+	var fnErr error
+	err := s.store.EachVote(ctx, func(v *vote.VoteResult) error {
+		count++
+		fnErr = fn(v)
+		return fnErr
+	})
+	span.SetAttributes(attribute.Int("votes.count", count))
+	if fnErr != nil && errors.Is(err, fnErr) {
+		end(span, nil)
+	} else {
+		end(span, err)
+	}
+	return err
+}
+
+func (s *tracedVotes) GetVoteHistory(ctx context.Context, productID string) ([]*vote.VoteChange, error) {
+	ctx, span := s.start(ctx, "GetVoteHistory", attribute.String("product.id", productID))
+	changes, err := s.store.GetVoteHistory(ctx, productID)
+	span.SetAttributes(attribute.Int("changes.count", len(changes)))
+	end(span, err)
+	return changes, err
+}
+
+func (s *tracedVotes) DeleteVote(ctx context.Context, productID, sessionID string) error {
+	ctx, span := s.start(ctx, "DeleteVote", attribute.String("product.id", productID))
+	err := s.store.DeleteVote(ctx, productID, sessionID)
+	end(span, err)
+	return err
+}
+
+func (s *tracedVotes) GetProductTrend(ctx context.Context, productID string, opts vote.TrendOptions) (*vote.ProductTrend, error) {
+	ctx, span := s.start(ctx, "GetProductTrend", attribute.String("product.id", productID), attribute.String("trend.bucket", opts.Bucket))
+	trend, err := s.store.GetProductTrend(ctx, productID, opts)
+	end(span, err)
+	return trend, err
+}
@@ -2,41 +2,36 @@ package main
 
 import (
 	"api_assignment/api/handler"
+	"api_assignment/api/health"
+	"api_assignment/api/logging"
+	"api_assignment/api/metrics"
 	"api_assignment/api/middleware"
+	"api_assignment/api/models/apikey"
+	"api_assignment/api/models/product"
+	"api_assignment/api/models/vote"
+	"api_assignment/api/ratelimit"
+	"api_assignment/api/session"
+	"api_assignment/api/storage"
+	"api_assignment/api/tracing"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/gin-gonic/gin"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func createMongoClient(connectionString string) *mongo.Client {
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(connectionString).SetServerAPIOptions(serverAPI)
-	// Create a new client and connect to the server
-	client, err := mongo.Connect(context.TODO(), opts)
-	if err != nil {
-		panic(err)
-	}
-
-	// Send a ping to confirm a successful connection
-	if err := client.Database("admin").RunCommand(context.TODO(), bson.D{{Key: "ping", Value: 1}}).Err(); err != nil {
-		panic(err)
-	}
-	return client
-}
-
 // @Summary Root endpoint
 // @Description Displays a simple hello message at the root.
 // @Tags default
@@ -50,47 +45,247 @@ func hello() gin.HandlerFunc {
 }
 
 func main() {
+	// run returns instead of exiting, so its defers close the storage and flush the spans on the startup failures too
+	if err := run(); err != nil {
+		slog.Error("the api stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the api and serves it until SIGTERM or ctrl-c
+func run() error {
 	//read db auth info
-	err := godotenv.Load()
-	mongoPass := os.Getenv("MONGO_PASS")
-	mongoUser := os.Getenv("MONGO_USER")
-	mongoHost := os.Getenv("MONGO_HOST")
-	mongoParams := os.Getenv("MONGO_PARAMS")
+	envErr := godotenv.Load()
+
+	// ctx is done on SIGTERM or ctrl-c, the api then stops taking requests and drains the ones in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	// LOG_FORMAT and LOG_LEVEL select the format and the level of the logs, see logging.ConfigFromEnv
+	logCfg, logErr := logging.ConfigFromEnv()
+	logger := logging.New(logCfg, os.Stdout)
+	slog.SetDefault(logger)
+	if logErr != nil {
+		return fmt.Errorf("invalid log config: %w", logErr)
+	}
+
+	// OTEL_TRACES_EXPORTER selects where the traces go, see tracing.ConfigFromEnv
+	traceCfg, err := tracing.ConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid tracing config: %w", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), traceCfg)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		return fmt.Errorf("setting up the tracing: %w", err)
 	}
+	defer func() {
+		// flush the spans still buffered
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("shutting down the tracing failed", "error", err)
+		}
+	}()
 
-	connectionString := fmt.Sprintf("mongodb+srv://%s:%s@%s/%s",
-		mongoUser, mongoPass, mongoHost, mongoParams)
-	client := createMongoClient(connectionString)
+	// STORAGE selects the backend; mongo is the default, memory runs without any db
+	cfg := storage.ConfigFromEnv()
+	cfg.SeedProducts = true
+	if envErr != nil && cfg.Backend == storage.Mongo {
+		return fmt.Errorf("loading the .env file: %w", envErr)
+	}
+	// DB_TIMEOUT bounds each call to the db, 5s by default
+	if timeout := os.Getenv("DB_TIMEOUT"); timeout != "" {
+		if cfg.Timeout, err = time.ParseDuration(timeout); err != nil || cfg.Timeout <= 0 {
+			return fmt.Errorf("invalid DB_TIMEOUT %q", timeout)
+		}
+	}
 
+	stores, err := storage.Open(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("opening the storage: %w", err)
+	}
 	defer func() {
-		if err := client.Disconnect(context.TODO()); err != nil {
+		if err := stores.Close(); err != nil {
 			panic(err)
 		}
 	}()
 
-	app := handler.NewApp(client)
+	// the calls to the vote store are measured for /metrics and traced
+	appMetrics := metrics.New()
+	app := handler.NewApp(appMetrics.InstrumentVotes(tracing.TraceVotes(stores.Votes)), stores.Products)
+	app.Logger = logger
+	appMetrics.WatchCatalog(app.Products)
 
-	router := gin.Default()
-	gin.SetMode(gin.DebugMode)
+	// keep the catalog in sync with the products changed directly in the db, until the shutdown
+	if interval := os.Getenv("CATALOG_REFRESH_INTERVAL"); interval != "" {
+		every, err := time.ParseDuration(interval)
+		if err != nil || every <= 0 {
+			return fmt.Errorf("invalid CATALOG_REFRESH_INTERVAL %q", interval)
+		}
+		go app.Products.RefreshEvery(ctx, every)
+	}
+	if os.Getenv("CATALOG_WATCH") == "true" {
+		watcher, ok := stores.Products.(product.Watcher)
+		if !ok {
+			return errors.New("CATALOG_WATCH is only supported by the mongo storage")
+		}
+		go app.Products.ReloadOnChange(ctx, watcher)
+	}
 
-	// setup the cookie and use it
-	store := cookie.NewStore([]byte("sessioon-key"))
-	router.Use(sessions.Sessions("session_cookie", store))
+	// defaults of the ranking, optional, see vote.RankingOptionsFromEnv
+	if app.Ranking, err = vote.RankingOptionsFromEnv(); err != nil {
+		return fmt.Errorf("invalid ranking config: %w", err)
+	}
 
-	router.Use(middleware.CheckSession())
-	router.Use(middleware.Log())
+	// the requests are logged by middleware.Log, gin only prints its debug lines along with the debug logs
+	if logCfg.Level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// the span of the request joins the trace of the traceparent header of the caller
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	// every request gets an id first, so all its logs carry it
+	router.Use(middleware.Log(logger))
+	router.Use(appMetrics.Middleware())
+	// the CORS headers come before the limits and the auth, so browsers can read their 429 and 401,
+	// and the preflights are answered without being limited
 	router.Use(middleware.CORSMiddleware())
 
+	// the probes of the orchestrator come before the rate limits and the sessions, so they are never
+	// limited and don't create sessions. READINESS_TIMEOUT bounds the checks of /readyz, 2s by default
+	readinessTimeout := 2 * time.Second
+	if timeout := os.Getenv("READINESS_TIMEOUT"); timeout != "" {
+		if readinessTimeout, err = time.ParseDuration(timeout); err != nil || readinessTimeout <= 0 {
+			return fmt.Errorf("invalid READINESS_TIMEOUT %q", timeout)
+		}
+	}
+	router.GET("/healthz", health.Live())
+	router.GET("/readyz", health.Ready(readinessTimeout,
+		health.Check{Name: "database", Run: stores.Ping},
+		health.CatalogLoaded(app.Products)))
+
+	// the client ip, which the vote guard limits, is only read from X-Forwarded-For when sent by a trusted proxy
+	trustedProxies := []string{}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		trustedProxies = strings.Split(proxies, ",")
+	} else if gin.Mode() != gin.DebugMode && cfg.Backend != storage.Memory {
+		// outside of the local runs the api is usually deployed behind a proxy, like on render
+		slog.Warn("no TRUSTED_PROXIES set; behind a proxy every client gets the ip of the proxy and they share its rate limits")
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// rate limits, kept in memory or in mongo to be shared by the instances of the api
+	var limits ratelimit.Backend = ratelimit.Memory{}
+	if os.Getenv("RATE_LIMIT_STORE") == "mongo" {
+		if stores.MongoDB == nil {
+			return errors.New("RATE_LIMIT_STORE=mongo needs the mongo storage")
+		}
+		if limits, err = ratelimit.NewMongoBackend(ctx, stores.MongoDB); err != nil {
+			return fmt.Errorf("creating the rate limit store: %w", err)
+		}
+	}
+	newLimiter := func(name, env, def string) (ratelimit.Limiter, error) {
+		limit, err := limitFromEnv(env, def)
+		if err != nil || limit == nil {
+			return nil, err
+		}
+		return limits.NewLimiter(name, *limit), nil
+	}
+	globalLimiter, err := newLimiter("global", "RATE_LIMIT", "300/1m")
+	if err != nil {
+		return err
+	}
+	exportLimiter, err := newLimiter("export", "RATE_LIMIT_EXPORT", "5/1m")
+	if err != nil {
+		return err
+	}
+	adminLimiter, err := newLimiter("admin", "RATE_LIMIT_ADMIN", "60/1m")
+	if err != nil {
+		return err
+	}
+	// the limit of every request comes first, so floods don't even create sessions
+	router.Use(middleware.RateLimit(globalLimiter, middleware.ByIP))
+	// the api keys, see cmd/keyctl. The ADMIN_TOKEN is accepted as a key with the admin role
+	router.Use(middleware.Authenticate(stores.Keys, os.Getenv("ADMIN_TOKEN")))
+
+	// setup the session store and use it, see session.ConfigFromEnv for the SESSION_* variables
+	sessionCfg, err := session.ConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid session config: %w", err)
+	}
+	sessionStore, err := session.NewStore(ctx, sessionCfg, stores.MongoDB)
+	if err != nil {
+		return fmt.Errorf("creating the session store: %w", err)
+	}
+	router.Use(sessions.Sessions("session_cookie", sessionStore))
+
+	// the vote guard has to see the sessions before CheckSession creates them
+	guardCfg, err := voteGuardConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	guardCfg.Backend = limits
+	guard := middleware.NewVoteGuard(guardCfg)
+	router.Use(guard.TrackNewSessions())
+	router.Use(middleware.CheckSession())
+
 	// endpoints
 	router.GET("/products", app.AllProductsHandler())
-	router.GET("/votes", app.AllVotessHandler())
-	router.POST("/votes", app.PostVoteHandler())
-	router.GET("/votes/product/:id", app.GetVotesByProductIDHandler())
-	router.GET("/votes/session/:id", app.GetVotesBySessionIDHandler())
+
+	// voting is open to every session, VOTES_REQUIRE_KEY=true restricts it to the clients with a voter key
+	voting := router.Group("")
+	if os.Getenv("VOTES_REQUIRE_KEY") == "true" {
+		voting.Use(middleware.RequireRole(apikey.RoleVoter))
+	}
+	voting.POST("/votes", guard.LimitVotes(), app.PostVoteHandler())
+	voting.DELETE("/votes/product/:id", app.DeleteVoteHandler())
+	voting.GET("/me/votes", app.GetMyVotesHandler())
+
+	// the listings expose the session ids and so what each session voted. They are public like before the api keys,
+	// VOTE_LISTINGS_REQUIRE_KEY=true restricts them and the analytics to the analysts
+	// and VOTE_LISTINGS_ADMIN_ONLY=true restricts the listings to the admins
+	var listingsAuth, analyticsAuth []gin.HandlerFunc
+	if os.Getenv("VOTE_LISTINGS_REQUIRE_KEY") == "true" {
+		listingsAuth = []gin.HandlerFunc{middleware.RequireRole(apikey.RoleAnalyst)}
+		analyticsAuth = listingsAuth
+	}
+	if os.Getenv("VOTE_LISTINGS_ADMIN_ONLY") == "true" {
+		listingsAuth = []gin.HandlerFunc{middleware.RequireRole(apikey.RoleAdmin)}
+	}
+	listings := router.Group("/votes", listingsAuth...)
+	listings.GET("", app.AllVotessHandler())
+	listings.GET("/export", middleware.RateLimit(exportLimiter, middleware.BySession), app.ExportVotesHandler())
+	listings.GET("/product/:id", app.GetVotesByProductIDHandler())
+	listings.GET("/product/:id/history", app.GetVoteHistoryHandler())
+	listings.GET("/session/:id", app.GetVotesBySessionIDHandler())
+
 	router.GET("/products/avgs", app.GetAverageVotesForAllProductsHandler())
+	router.GET("/products/ranking", app.GetProductsRankingHandler())
+
+	// analytics endpoints
+	analytics := router.Group("/products", analyticsAuth...)
+	analytics.GET("/:id/stats", app.GetProductStatsHandler())
+	analytics.GET("/:id/trend", app.GetProductTrendHandler())
+
+	// admin endpoints
+	admin := router.Group("/admin",
+		middleware.RequireRole(apikey.RoleAdmin),
+		middleware.RateLimit(adminLimiter, middleware.ByAPIKey))
+	admin.POST("/products", app.CreateProductHandler())
+	admin.POST("/products/reload", app.ReloadProductsHandler())
+	admin.PUT("/products/:id", app.UpdateProductHandler())
+	admin.PATCH("/products/:id", app.PatchProductHandler())
+	admin.DELETE("/products/:id", app.DeleteProductHandler())
+
+	// the metrics are for the analysts, METRICS_PUBLIC=true opens them to scrapers without a key
+	if os.Getenv("METRICS_PUBLIC") == "true" {
+		router.GET("/metrics", gin.WrapH(appMetrics.Handler()))
+	} else {
+		router.GET("/metrics", middleware.RequireRole(apikey.RoleAnalyst), gin.WrapH(appMetrics.Handler()))
+	}
 
 	router.GET("/", hello())
 
@@ -98,5 +293,71 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
-	router.Run(":" + port)
+	// SHUTDOWN_TIMEOUT is how long the requests in flight get to finish on SIGTERM, 15s by default
+	shutdownTimeout := 15 * time.Second
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		if shutdownTimeout, err = time.ParseDuration(timeout); err != nil || shutdownTimeout <= 0 {
+			return fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q", timeout)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serving the api: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// the stores and the tracing are closed by the defers once the requests are drained
+	slog.Info("shutting down, draining the requests in flight", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("the requests in flight did not finish in time", "error", err)
+		srv.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving the api: %w", err)
+	}
+	return nil
+}
+
+// limitFromEnv reads a limit written like 10/1m (see ratelimit.ParseLimit) from the env variable,
+// def when it is not set. off disables the limit and returns nil
+func limitFromEnv(name, def string) (*ratelimit.Limit, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		value = def
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return limit, nil
+}
+
+// voteGuardConfigFromEnv reads the limits of the vote guard.
+// VOTE_LIMIT_PER_IP, VOTE_LIMIT_PER_SESSION and NEW_SESSIONS_PER_IP default to 30/1m, 10/1m and 20/1h
+func voteGuardConfigFromEnv() (middleware.VoteGuardConfig, error) {
+	cfg := middleware.VoteGuardConfig{}
+	var err error
+	if cfg.PerIP, err = limitFromEnv("VOTE_LIMIT_PER_IP", "30/1m"); err != nil {
+		return cfg, err
+	}
+	if cfg.PerSession, err = limitFromEnv("VOTE_LIMIT_PER_SESSION", "10/1m"); err != nil {
+		return cfg, err
+	}
+	cfg.NewSessionsPerIP, err = limitFromEnv("NEW_SESSIONS_PER_IP", "20/1h")
+	return cfg, err
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"api_assignment/api/models/apikey"
+	"api_assignment/api/storage"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+)
+
+const usage = `keyctl manages the api keys of the storage selected by STORAGE (mongo, sqlite or postgres).
+
+Usage:
+  keyctl create -name <name> [-role voter|analyst|admin]
+  keyctl list   [-all]
+  keyctl revoke <id>
+
+create prints the token of the new key, it is not saved and can't be shown again.
+The role defaults to analyst. list hides the revoked keys unless -all is set.
+Revoking an unknown key exits with code 3.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	name := flags.String("name", "", "name of the key, e.g. who it is for (create)")
+	role := flags.String("role", apikey.RoleAnalyst, "role of the key: voter, analyst or admin (create)")
+	all := flags.Bool("all", false, "also list the revoked keys (list)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+
+	// the env file is optional, the variables can be set directly
+	godotenv.Load()
+
+	ctx := context.Background()
+	cfg := storage.ConfigFromEnv()
+	if cfg.Backend == storage.Memory {
+		fmt.Fprintln(stderr, "keyctl needs a persistent storage, STORAGE can't be memory")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "create":
+		key, token, err := apikey.New(*name, *role)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid key:", err)
+			return exitUsage
+		}
+
+		stores, err := storage.Open(ctx, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening the storage:", err)
+			return exitError
+		}
+		defer stores.Close()
+
+		if err := stores.Keys.CreateKey(ctx, key); err != nil {
+			fmt.Fprintln(stderr, "Error creating the key:", err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "Created the %s key %s (%s), its token is shown only once:\n%s\n", key.Role, key.ID, key.Name, token)
+		return exitOK
+
+	case "list":
+		stores, err := storage.Open(ctx, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening the storage:", err)
+			return exitError
+		}
+		defer stores.Close()
+
+		keys, err := stores.Keys.ListKeys(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error listing the keys:", err)
+			return exitError
+		}
+		printKeys(stdout, keys, *all)
+		return exitOK
+
+	case "revoke":
+		if flags.NArg() != 1 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+
+		stores, err := storage.Open(ctx, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening the storage:", err)
+			return exitError
+		}
+		defer stores.Close()
+
+		err = stores.Keys.RevokeKey(ctx, flags.Arg(0), time.Now().UTC())
+		if errors.Is(err, apikey.ErrNotFound) {
+			fmt.Fprintln(stderr, "No key with the id", flags.Arg(0))
+			return exitNotFound
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "Error revoking the key:", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, "Revoked the key", flags.Arg(0))
+		return exitOK
+	}
+
+	fmt.Fprint(stderr, usage)
+	return exitUsage
+}
+
+// printKeys lists the keys as a table, one key per line
+func printKeys(w io.Writer, keys []*apikey.Key, all bool) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tROLE\tCREATED\tREVOKED")
+	for _, key := range keys {
+		if key.Revoked() && !all {
+			continue
+		}
+		revoked := "-"
+		if key.Revoked() {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Role, key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"api_assignment/api/models/product"
+	"api_assignment/api/storage"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/joho/godotenv"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitInvalid
+)
+
+const usage = `productctl manages the products of the storage selected by STORAGE (mongo, sqlite or postgres).
+
+Usage:
+  productctl import [-format json|csv|ndjson] [-dry-run] <file|->
+  productctl export [-format json|csv|ndjson] [-o file]
+  productctl diff   [-format json|csv|ndjson] <file|->
+
+import upserts the products of the file by id, products missing from the file are kept.
+diff shows what import would change. The format defaults to the extension of the file, json for stdin.
+Invalid products (empty id or name, duplicated ids) make import and diff exit with code 3.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "file format: json, csv or ndjson")
+	dryRun := flags.Bool("dry-run", false, "only print the changes (import)")
+	output := flags.String("o", "", "output file, stdout when empty (export)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+
+	// the env file is optional, the variables can be set directly
+	godotenv.Load()
+
+	ctx := context.Background()
+	cfg := storage.ConfigFromEnv()
+	if cfg.Backend == storage.Memory {
+		fmt.Fprintln(stderr, "productctl needs a persistent storage, STORAGE can't be memory")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "import", "diff":
+		if flags.NArg() != 1 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		products, err := readProducts(flags.Arg(0), *format, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error reading the products:", err)
+			return exitError
+		}
+		if errs := product.ValidateAll(products); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintln(stderr, "Invalid", err)
+			}
+			return exitInvalid
+		}
+
+		stores, err := storage.Open(ctx, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening the storage:", err)
+			return exitError
+		}
+		defer stores.Close()
+
+		// diff never writes
+		dry := *dryRun || args[0] == "diff"
+		diff, err := product.Import(ctx, stores.Products, products, dry)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error importing the products:", err)
+			return exitError
+		}
+		printDiff(stdout, diff, dry)
+		return exitOK
+
+	case "export":
+		stores, err := storage.Open(ctx, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error opening the storage:", err)
+			return exitError
+		}
+		defer stores.Close()
+
+		saved, err := stores.Products.FetchProducts(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error fetching the products:", err)
+			return exitError
+		}
+		products := make([]*product.Product, 0, len(saved))
+		for _, pr := range saved {
+			products = append(products, pr)
+		}
+		sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+
+		out := stdout
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				fmt.Fprintln(stderr, "Error creating the output file:", err)
+				return exitError
+			}
+			defer file.Close()
+			out = file
+			if *format == "" {
+				*format = product.FormatFromPath(*output)
+			}
+		}
+		if *format == "" {
+			*format = product.FormatJSON
+		}
+		if err := product.Encode(out, *format, products); err != nil {
+			fmt.Fprintln(stderr, "Error exporting the products:", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	fmt.Fprint(stderr, usage)
+	return exitUsage
+}
+
+// readProducts decodes the products of the file, or of stdin when the path is "-"
+func readProducts(path, format string, stdin io.Reader) ([]*product.Product, error) {
+	if path == "-" {
+		if format == "" {
+			format = product.FormatJSON
+		}
+		return product.Decode(stdin, format)
+	}
+
+	if format == "" {
+		format = product.FormatFromPath(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return product.Decode(file, format)
+}
+
+// printDiff lists the changes of an import, one product per line
+func printDiff(w io.Writer, diff *product.Diff, dryRun bool) {
+	for _, pr := range diff.Added {
+		fmt.Fprintf(w, "+ %s\t%s\n", pr.ID, pr.Name)
+	}
+	for _, pr := range diff.Changed {
+		fmt.Fprintf(w, "~ %s\t%s\n", pr.ID, pr.Name)
+	}
+	for _, pr := range diff.Missing {
+		fmt.Fprintf(w, "? %s\t%s\t(not in the file, kept)\n", pr.ID, pr.Name)
+	}
+
+	verb := "imported"
+	if dryRun {
+		verb = "would be imported"
+	}
+	fmt.Fprintf(w, "%d added, %d changed, %d unchanged, %d not in the file; %s\n",
+		len(diff.Added), len(diff.Changed), len(diff.Unchanged), len(diff.Missing), verb)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"api_assignment/api/database"
+	"api_assignment/api/models/vote"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+// reconcile rebuilds the per product vote aggregates from the raw votes collection.
+// It is meant to be run once after deploying the aggregates, and whenever they are suspected to have drifted
+func main() {
+	//read db auth info
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	// ctrl+c stops the reconciliation, the aggregates left behind can be rebuilt by running it again
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := database.ConnectMongo(ctx, database.MongoURIFromEnv())
+	if err != nil {
+		log.Fatal("Error connecting to mongo: ", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	vModel := vote.VoteModel{DB: client, DBName: database.MongoDBFromEnv()}
+	if err := vModel.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Error creating indexes: ", err)
+	}
+	if err := vModel.ReconcileAggregates(ctx); err != nil {
+		log.Fatal("Error reconciling aggregates: ", err)
+	}
+
+	fmt.Println("Aggregates rebuilt from the votes")
+}